@@ -44,7 +44,8 @@ func _main() error {
 		}
 	}
 
-	var s3EndpointURL string
+	var s3EndpointURL, statePullBinary string
+	var statePull bool
 	flag.StringVar(&stateLoc, "state", defaultStateFile, "tfstate file path or URL")
 	flag.StringVar(&stateLoc, "s", defaultStateFile, "tfstate file path or URL")
 	flag.BoolVar(&interactive, "i", false, "interactive mode")
@@ -52,6 +53,8 @@ func _main() error {
 	flag.BoolVar(&dump, "dump", false, "dump all resources")
 	flag.StringVar(&s3EndpointURL, "s3-endpoint-url", "", "S3 endpoint URL")
 	flag.DurationVar(&timeout, "timeout", 0, "timeout for reading tfstate")
+	flag.BoolVar(&statePull, "state-pull", false, "run terraform state pull for unsupported backends")
+	flag.StringVar(&statePullBinary, "state-pull-binary", "", "binary for -state-pull (default terraform, or tofu if terraform is not found)")
 	flag.Parse()
 
 	var ctx = context.Background()
@@ -65,6 +68,9 @@ func _main() error {
 	if s3EndpointURL != "" {
 		opts = append(opts, tfstate.S3EndpointOption(s3EndpointURL))
 	}
+	if statePull || statePullBinary != "" {
+		opts = append(opts, tfstate.StatePullOption{Binary: statePullBinary})
+	}
 	state, err := tfstate.ReadURL(ctx, stateLoc, opts...)
 	if err != nil {
 		return err
@@ -0,0 +1,389 @@
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Backend reads states from a backend of terraform.
+type Backend interface {
+	// ReadState reads the state of the workspace.
+	// config is the config of the backend block (the backend.config of .terraform/terraform.tfstate).
+	ReadState(ctx context.Context, config map[string]any, workspace string) (io.ReadCloser, error)
+}
+
+// BackendFunc is an adapter to use an ordinary function as a Backend.
+type BackendFunc func(ctx context.Context, config map[string]any, workspace string) (io.ReadCloser, error)
+
+// ReadState calls f(ctx, config, workspace).
+func (f BackendFunc) ReadState(ctx context.Context, config map[string]any, workspace string) (io.ReadCloser, error) {
+	return f(ctx, config, workspace)
+}
+
+// StateVersionLister is implemented by a Backend which lists the versions of states for ListStateVersions.
+type StateVersionLister interface {
+	// ListStateVersions lists the versions of the state of the workspace, newest first.
+	ListStateVersions(ctx context.Context, config map[string]any, workspace string) ([]StateVersionInfo, error)
+}
+
+// versionedBackend is a built-in backend which lists the versions of states.
+type versionedBackend struct {
+	BackendFunc
+	list func(ctx context.Context, config map[string]any, workspace string) ([]StateVersionInfo, error)
+}
+
+func (b versionedBackend) ListStateVersions(ctx context.Context, config map[string]any, workspace string) ([]StateVersionInfo, error) {
+	return b.list(ctx, config, workspace)
+}
+
+// URLParser converts a URL to the config and the workspace of a backend.
+type URLParser func(u *url.URL) (config map[string]any, workspace string, err error)
+
+// urlScheme reads states of the URLs of a scheme by the backend.
+type urlScheme struct {
+	backend Backend
+	parse   URLParser
+}
+
+var (
+	registryMu sync.RWMutex
+
+	// backends are the backends by the backend type.
+	backends = map[string]Backend{
+		"gcs":        versionedBackend{readGCSState, listGCSStateVersions},
+		"azurerm":    versionedBackend{readAzureRMState, listAzureRMStateVersions},
+		"s3":         versionedBackend{readS3State, listS3StateVersions},
+		"remote":     versionedBackend{readTFEState, listTFEStateVersions},
+		"cloud":      versionedBackend{readTFECloudState, listTFECloudStateVersions},
+		"http":       BackendFunc(readHTTPState),
+		"consul":     BackendFunc(readConsulState),
+		"pg":         BackendFunc(readPgState),
+		"kubernetes": BackendFunc(readKubernetesState),
+		"oss":        BackendFunc(readOSSState),
+		"oci":        BackendFunc(readOCIState),
+		"local":      BackendFunc(readLocalState),
+	}
+
+	// schemes are the backends and the parsers by the URL scheme.
+	schemes = map[string]urlScheme{
+		"http":       {backendOf("http"), parseHTTPURL},
+		"https":      {backendOf("http"), parseHTTPURL},
+		"s3":         {backendOf("s3"), parseS3URL},
+		"oss":        {backendOf("oss"), parseOSSURL},
+		"oci":        {backendOf("oci"), parseOCIURL},
+		"gs":         {backendOf("gcs"), parseGCSURL},
+		"azurerm":    {backendOf("azurerm"), parseAzureRMURL},
+		"consul":     {backendOf("consul"), parseConsulURL},
+		"pg":         {backendOf("pg"), parsePgURL},
+		"kubernetes": {backendOf("kubernetes"), parseKubernetesURL},
+		"file":       {backendOf("local"), parseFileURL},
+		"remote":     {backendOf("remote"), parseTFEURL},
+	}
+)
+
+// RegisterBackend registers the backend for the backend type.
+// The backend is used to read states of .terraform/terraform.tfstate whose backend type is typ,
+// and of the URL schemes converted to typ. It replaces the built-in backend of the type if exists.
+func RegisterBackend(typ string, b Backend) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	backends[typ] = b
+}
+
+// RegisterScheme registers the URL scheme for ReadURL.
+// A URL of the scheme is converted by parse to the config and the workspace,
+// and the state is read by the backend registered for backendType.
+// It replaces the built-in scheme if exists.
+func RegisterScheme(scheme string, backendType string, parse URLParser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	schemes[scheme] = urlScheme{backend: backendOf(backendType), parse: parse}
+}
+
+// backendOf returns a Backend which reads states by the backend registered for the type at the time of reading.
+func backendOf(typ string) Backend {
+	return registeredBackend(typ)
+}
+
+// registeredBackend is the backend registered for the type.
+type registeredBackend string
+
+func (typ registeredBackend) ReadState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	b, ok := lookupBackend(string(typ))
+	if !ok {
+		return nil, fmt.Errorf("backend type %s is not supported", typ)
+	}
+	return b.ReadState(ctx, config, ws)
+}
+
+func (typ registeredBackend) ListStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	b, ok := lookupBackend(string(typ))
+	if !ok {
+		return nil, fmt.Errorf("backend type %s is not supported", typ)
+	}
+	l, ok := b.(StateVersionLister)
+	if !ok {
+		return nil, fmt.Errorf("listing versions of backend type %s is not supported", typ)
+	}
+	return l.ListStateVersions(ctx, config, ws)
+}
+
+// lookupBackend returns the backend registered for the type, or the external backend in PATH.
+func lookupBackend(typ string) (Backend, bool) {
+	registryMu.RLock()
+	b, ok := backends[typ]
+	registryMu.RUnlock()
+	if ok {
+		return b, true
+	}
+	if path, ok := lookupPlugin(typ); ok {
+		return pluginBackend{typ: typ, path: path}, true
+	}
+	return nil, false
+}
+
+// lookupScheme returns the URL scheme registered, or the external backend in PATH.
+func lookupScheme(scheme string) (urlScheme, bool) {
+	registryMu.RLock()
+	s, ok := schemes[scheme]
+	registryMu.RUnlock()
+	if ok {
+		return s, true
+	}
+	if path, ok := lookupPlugin(scheme); ok {
+		return pluginScheme(path), true
+	}
+	return urlScheme{}, false
+}
+
+// readURLState reads the state of the URL by the backend of the scheme.
+// The options of ReadURL are passed to the backend through ctx.
+func readURLState(ctx context.Context, u *url.URL, cfg *readURLConfig) (io.ReadCloser, error) {
+	s, ok := lookupScheme(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("URL scheme %s is not supported", u.Scheme)
+	}
+	config, ws, err := s.parseURL(u)
+	if err != nil {
+		return nil, err
+	}
+	return s.backend.ReadState(withReadURLConfig(ctx, cfg), config, ws)
+}
+
+// parseURL converts the URL to the config and the workspace, which defaults to the default workspace.
+func (s urlScheme) parseURL(u *url.URL) (map[string]any, string, error) {
+	config, ws, err := s.parse(u)
+	if err != nil {
+		return nil, "", err
+	}
+	if ws == "" {
+		ws = defaultWorkspace
+	}
+	return config, ws, nil
+}
+
+func readRemoteState(ctx context.Context, b *backend, ws string, cfg *readURLConfig) (io.ReadCloser, error) {
+	rb, ok := lookupBackend(b.Type)
+	if !ok {
+		if cfg != nil && cfg.statePull != nil {
+			return readStatePull(ctx, cfg.statePull.Binary, b.dir, ws)
+		}
+		return nil, fmt.Errorf("backend type %s is not supported", b.Type)
+	}
+	return rb.ReadState(withWorkDir(ctx, b.dir), b.Config, ws)
+}
+
+type (
+	readURLConfigKey struct{}
+	workDirKey       struct{}
+)
+
+func withReadURLConfig(ctx context.Context, cfg *readURLConfig) context.Context {
+	return context.WithValue(ctx, readURLConfigKey{}, cfg)
+}
+
+// readURLConfigFrom returns the options of ReadURL, or nil for a backend of .terraform/terraform.tfstate.
+func readURLConfigFrom(ctx context.Context) *readURLConfig {
+	cfg, _ := ctx.Value(readURLConfigKey{}).(*readURLConfig)
+	return cfg
+}
+
+func withWorkDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, workDirKey{}, dir)
+}
+
+// workDirFrom returns the working directory of terraform to resolve relative paths in the backend config.
+// It is empty for URLs, which resolves them from the current directory.
+func workDirFrom(ctx context.Context) string {
+	dir, _ := ctx.Value(workDirKey{}).(string)
+	return dir
+}
+
+// setVersionQuery sets the version selector in the URL query to version_id and as_of of the config.
+// versionKey is the name of the version ID in the query.
+func setVersionQuery(config map[string]any, q url.Values, versionKey string) {
+	if v := q.Get(versionKey); v != "" {
+		config["version_id"] = v
+	}
+	if v := q.Get("asOf"); v != "" {
+		config["as_of"] = v
+	}
+}
+
+// parseHTTPURL converts http(s)://... to the config of the http backend.
+func parseHTTPURL(u *url.URL) (map[string]any, string, error) {
+	return map[string]any{"address": u.String()}, "", nil
+}
+
+// parseS3URL converts s3://{bucket}/{key}?versionId=...&asOf=...
+func parseS3URL(u *url.URL) (map[string]any, string, error) {
+	config := map[string]any{
+		"bucket": u.Host,
+		"key":    strings.TrimPrefix(u.Path, "/"),
+	}
+	setVersionQuery(config, u.Query(), "versionId")
+	return config, "", nil
+}
+
+// parseOSSURL converts oss://{bucket}/{key}
+func parseOSSURL(u *url.URL) (map[string]any, string, error) {
+	return map[string]any{
+		"bucket": u.Host,
+		"prefix": "",
+		"key":    strings.TrimPrefix(u.Path, "/"),
+	}, "", nil
+}
+
+// parseOCIURL converts oci://{namespace}/{bucket}/{key}?auth=...&profile=...&region=...
+func parseOCIURL(u *url.URL) (map[string]any, string, error) {
+	split := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if len(split) < 2 {
+		return nil, "", fmt.Errorf("invalid oci url: %s", u.String())
+	}
+	config := map[string]any{
+		"namespace": u.Host,
+		"bucket":    split[0],
+		"key":       split[1],
+	}
+	q := u.Query()
+	for k, key := range map[string]string{
+		"auth":    "auth",
+		"profile": "config_file_profile",
+		"region":  "region",
+	} {
+		if v := q.Get(k); v != "" {
+			config[key] = v
+		}
+	}
+	return config, "", nil
+}
+
+// parseGCSURL converts gs://{bucket}/{key}#{generation} or gs://{bucket}/{key}?generation=...&asOf=...
+// key is not a config of the gcs backend of terraform, but selects the object instead of prefix and the workspace.
+func parseGCSURL(u *url.URL) (map[string]any, string, error) {
+	config := map[string]any{
+		"bucket": u.Host,
+		"key":    strings.TrimPrefix(u.Path, "/"),
+	}
+	setVersionQuery(config, u.Query(), "generation")
+	if u.Fragment != "" {
+		config["version_id"] = u.Fragment
+	}
+	return config, "", nil
+}
+
+// parseAzureRMURL converts azurerm://[{subscription_id}@]{resource_group_name}/{storage_account_name}/{container_name}/{blob_name}?snapshot=...&versionid=...&asOf=...
+func parseAzureRMURL(u *url.URL) (map[string]any, string, error) {
+	split := strings.SplitN(u.Path, "/", 4)
+	if len(split) < 4 {
+		return nil, "", fmt.Errorf("invalid azurerm url: %s", u.Redacted())
+	}
+	config := map[string]any{
+		"resource_group_name":  u.Host,
+		"storage_account_name": split[1],
+		"container_name":       split[2],
+		"key":                  split[3],
+	}
+	if s := u.User.Username(); s != "" {
+		config["subscription_id"] = s
+	}
+	q := u.Query()
+	if v := q.Get("snapshot"); v != "" {
+		config["snapshot"] = v
+	}
+	setVersionQuery(config, q, "versionid")
+	return config, "", nil
+}
+
+// parseConsulURL converts consul://{address}/{path}
+func parseConsulURL(u *url.URL) (map[string]any, string, error) {
+	config := map[string]any{"path": strings.TrimPrefix(u.Path, "/")}
+	if u.Host != "" {
+		config["address"] = u.Host
+	}
+	return config, "", nil
+}
+
+// parsePgURL converts pg://user:pass@host/dbname?schema_name=...&workspace=...
+func parsePgURL(u *url.URL) (map[string]any, string, error) {
+	q := u.Query()
+	schemaName, ws := q.Get("schema_name"), q.Get("workspace")
+	q.Del("schema_name")
+	q.Del("workspace")
+	connURL := *u
+	connURL.Scheme = "postgres"
+	connURL.RawQuery = q.Encode()
+	config := map[string]any{"conn_str": connURL.String()}
+	if schemaName != "" {
+		config["schema_name"] = schemaName
+	}
+	return config, ws, nil
+}
+
+// parseKubernetesURL converts kubernetes://{namespace}/{secret_suffix}?workspace=...&context=...
+func parseKubernetesURL(u *url.URL) (map[string]any, string, error) {
+	q := u.Query()
+	config := map[string]any{"secret_suffix": strings.TrimPrefix(u.Path, "/")}
+	if u.Host != "" {
+		config["namespace"] = u.Host
+	}
+	if c := q.Get("context"); c != "" {
+		config["config_context"] = c
+	}
+	return config, q.Get("workspace"), nil
+}
+
+// parseFileURL converts file://{path} to the config of the local backend.
+func parseFileURL(u *url.URL) (map[string]any, string, error) {
+	return map[string]any{"path": u.Path}, "", nil
+}
+
+// parseTFEURL converts remote://{hostname}/{organization}/{workspace}?versionId=...&serial=...&run=...&asOf=...&outputsOnly=true
+// serial, run_id and outputs_only are not configs of the remote backend of terraform, but select the state version.
+func parseTFEURL(u *url.URL) (map[string]any, string, error) {
+	split := strings.Split(u.Path, "/")
+	if len(split) < 3 {
+		return nil, "", fmt.Errorf("invalid remote url: %s", u.Redacted())
+	}
+	config := map[string]any{
+		"hostname":     u.Host,
+		"organization": split[1],
+		"workspaces":   map[string]any{"name": split[2]},
+	}
+	q := u.Query()
+	for k, key := range map[string]string{
+		"serial":      "serial",
+		"run":         "run_id",
+		"outputsOnly": "outputs_only",
+	} {
+		if v := q.Get(k); v != "" {
+			config[key] = v
+		}
+	}
+	setVersionQuery(config, q, "versionId")
+	return config, "", nil
+}
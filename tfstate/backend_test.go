@@ -0,0 +1,144 @@
+package tfstate_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// artifactBackend is a backend of an imaginary artifact service, which stores states in test/{name}.tfstate.
+type artifactBackend struct {
+	calls []string
+}
+
+func (b *artifactBackend) ReadState(ctx context.Context, config map[string]any, workspace string) (io.ReadCloser, error) {
+	b.calls = append(b.calls, fmt.Sprintf("%s/%s", config["name"], workspace))
+	if config["name"] != "terraform" {
+		return nil, fmt.Errorf("artifact %v is not found", config["name"])
+	}
+	return os.Open("test/terraform.tfstate")
+}
+
+// listerBackend is a backend which lists the versions of states.
+type listerBackend struct {
+	tfstate.Backend
+	list func(ctx context.Context, config map[string]any, workspace string) ([]tfstate.StateVersionInfo, error)
+}
+
+func (b listerBackend) ListStateVersions(ctx context.Context, config map[string]any, workspace string) ([]tfstate.StateVersionInfo, error) {
+	return b.list(ctx, config, workspace)
+}
+
+var testRegistryID atomic.Int64
+
+// testRegistryName returns the unique name of a backend type and a URL scheme for the test run.
+// The backend and the scheme of the name are unregistered when the test finishes.
+func testRegistryName(t *testing.T, prefix string) string {
+	name := fmt.Sprintf("%s-%d", prefix, testRegistryID.Add(1))
+	t.Cleanup(func() {
+		tfstate.UnregisterBackend(name)
+		tfstate.UnregisterScheme(name)
+	})
+	return name
+}
+
+func TestRegisterBackend(t *testing.T) {
+	b := &artifactBackend{}
+	artifact := testRegistryName(t, "test-artifact")
+	tfstate.RegisterBackend(artifact, b)
+	tfstate.RegisterScheme(artifact, artifact, func(u *url.URL) (map[string]any, string, error) {
+		return map[string]any{"name": u.Host}, u.Query().Get("workspace"), nil
+	})
+
+	t.Run("backend", func(t *testing.T) {
+		src := fmt.Sprintf(`{"version": 3, "backend": {"type": %q, "config": {"name": "terraform"}}}`, artifact)
+		state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(src), "staging")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("scheme", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), artifact+"://terraform")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+		if _, err := tfstate.ReadURL(t.Context(), artifact+"://missing?workspace=prod"); err == nil {
+			t.Error("expected error for a missing artifact")
+		}
+	})
+
+	expected := []string{"terraform/staging", "terraform/default", "missing/prod"}
+	if strings.Join(b.calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected calls %v, expected %v", b.calls, expected)
+	}
+
+	t.Run("BackendFunc", func(t *testing.T) {
+		name := testRegistryName(t, "test-func")
+		tfstate.RegisterScheme(name, name, func(u *url.URL) (map[string]any, string, error) {
+			return nil, "", nil
+		})
+		if _, err := tfstate.ReadURL(t.Context(), name+"://x"); err == nil {
+			t.Error("expected error for an unregistered backend type")
+		}
+		tfstate.RegisterBackend(name, tfstate.BackendFunc(func(ctx context.Context, config map[string]any, workspace string) (io.ReadCloser, error) {
+			return os.Open("test/terraform.tfstate")
+		}))
+		state, err := tfstate.ReadURL(t.Context(), name+"://x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("ListStateVersions", func(t *testing.T) {
+		if _, err := tfstate.ListStateVersions(t.Context(), artifact+"://terraform"); err == nil {
+			t.Error("expected error for a backend which does not list versions")
+		}
+		expected := []tfstate.StateVersionInfo{{VersionID: "v2", Serial: 2, IsLatest: true}, {VersionID: "v1", Serial: 1}}
+		var got map[string]any
+		defer tfstate.SetBackend("s3", listerBackend{
+			Backend: b,
+			list: func(ctx context.Context, config map[string]any, workspace string) ([]tfstate.StateVersionInfo, error) {
+				got = config
+				return expected, nil
+			},
+		})()
+		versions, err := tfstate.ListStateVersions(t.Context(), "s3://mybucket/terraform.tfstate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(versions) != fmt.Sprint(expected) {
+			t.Errorf("unexpected versions %v", versions)
+		}
+		if got["bucket"] != "mybucket" || got["key"] != "terraform.tfstate" {
+			t.Errorf("unexpected config %v", got)
+		}
+	})
+
+	t.Run("built-in scheme", func(t *testing.T) {
+		var got map[string]any
+		defer tfstate.SetBackend("s3", tfstate.BackendFunc(func(ctx context.Context, config map[string]any, workspace string) (io.ReadCloser, error) {
+			got = config
+			return os.Open("test/terraform.tfstate")
+		}))()
+		state, err := tfstate.ReadURL(t.Context(), "s3://mybucket/path/to/terraform.tfstate?versionId=v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+		expected := map[string]any{"bucket": "mybucket", "key": "path/to/terraform.tfstate", "version_id": "v1"}
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("unexpected config %v, expected %v", got, expected)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package tfstate
+
+// UnregisterBackend removes the backend registered for the type.
+func UnregisterBackend(typ string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(backends, typ)
+}
+
+// UnregisterScheme removes the URL scheme.
+func UnregisterScheme(scheme string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(schemes, scheme)
+}
+
+// SetBackend replaces the backend registered for the type and returns a function to restore it.
+func SetBackend(typ string, b Backend) func() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	orig, ok := backends[typ]
+	backends[typ] = b
+	return func() {
+		if ok {
+			RegisterBackend(typ, orig)
+		} else {
+			UnregisterBackend(typ)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package tfstate
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPRetryWait returns the wait of the retry option after the attempt responded with the Retry-After header.
+func HTTPRetryWait(o HTTPRetryOption, attempt int, retryAfter string) time.Duration {
+	resp := &http.Response{Header: make(http.Header)}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return o.wait(attempt, resp)
+}
+
+// NetrcCredentials returns the login and password for the host in the .netrc file.
+func NetrcCredentials(path string, host string) (string, string, error) {
+	return netrcCredentials(path, host)
+}
@@ -0,0 +1,10 @@
+//go:build !no_oss
+
+package tfstate
+
+import "context"
+
+// NewOSSStateOption returns the option to read the state by the oss backend config.
+func NewOSSStateOption(config map[string]any) OSSOption {
+	return *newOSSStateOption(context.Background(), config)
+}
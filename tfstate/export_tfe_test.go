@@ -0,0 +1,10 @@
+//go:build !no_tfe
+
+package tfstate
+
+// SetTFEScheme replaces the URL scheme to access TFE and returns a function to restore it.
+func SetTFEScheme(scheme string) func() {
+	orig := tfeScheme
+	tfeScheme = scheme
+	return func() { tfeScheme = orig }
+}
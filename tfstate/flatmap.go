@@ -0,0 +1,109 @@
+package tfstate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unflatten converts a flatmap of the legacy states (attributes of state version 1-3
+// and attributes_flat of version 4) to nested maps and lists.
+//
+// In a flatmap, "name.#" is the count of a list or a set, "name.%" is the count of a map,
+// and their elements are "name.0", "name.key" or "name.{hash}.attr" (nested blocks of sets).
+// The values are kept as strings because the types are not recorded in a flatmap.
+func unflatten(flat map[string]string) map[string]any {
+	return unflattenMap(flat, "", names(flat, ""))
+}
+
+// names returns the distinct first segments of the keys under the prefix, excluding count keys.
+func names(flat map[string]string, prefix string) []string {
+	seen := make(map[string]struct{})
+	for k := range flat {
+		rest, ok := strings.CutPrefix(k, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(rest, ".")
+		if name == "#" || name == "%" {
+			continue
+		}
+		seen[name] = struct{}{}
+	}
+	ns := make([]string, 0, len(seen))
+	for n := range seen {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+func unflattenMap(flat map[string]string, prefix string, keys []string) map[string]any {
+	m := make(map[string]any, len(keys))
+	for _, k := range keys {
+		m[k] = unflattenValue(flat, prefix+k)
+	}
+	return m
+}
+
+func unflattenValue(flat map[string]string, key string) any {
+	if v, ok := flat[key]; ok {
+		return v
+	}
+	prefix := key + "."
+	if _, ok := flat[prefix+"#"]; ok {
+		keys := names(flat, prefix)
+		indexes := make([]int, 0, len(keys))
+		for _, k := range keys {
+			i, err := strconv.Atoi(k)
+			if err != nil {
+				// Maps of the state version 1 are also counted by "#".
+				return unflattenMap(flat, prefix, keys)
+			}
+			indexes = append(indexes, i)
+		}
+		// Elements of a list are ordered by the index, and of a set by the hash.
+		sort.Ints(indexes)
+		list := make([]any, 0, len(indexes))
+		for _, i := range indexes {
+			list = append(list, unflattenValue(flat, prefix+strconv.Itoa(i)))
+		}
+		return list
+	}
+	if count, ok := flat[prefix+"%"]; ok {
+		// Keys of a map may contain dots (e.g. tags of "kubernetes.io/cluster/name").
+		// When all the rest of the keys are values and the count matches, they are the keys of the map.
+		var leaves []string
+		for k := range flat {
+			if rest, ok := strings.CutPrefix(k, prefix); ok && rest != "%" {
+				leaves = append(leaves, rest)
+			}
+		}
+		if strconv.Itoa(len(leaves)) == count {
+			m := make(map[string]any, len(leaves))
+			for _, k := range leaves {
+				m[k] = flat[prefix+k]
+			}
+			return m
+		}
+	}
+	return unflattenMap(flat, prefix, names(flat, prefix))
+}
+
+// unflattenAttributes converts attributes_flat of an instance to nested attributes.
+// It returns the value as is if it is not a flatmap of strings.
+func unflattenAttributes(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	flat := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return m
+		}
+		flat[k] = s
+	}
+	return unflatten(flat)
+}
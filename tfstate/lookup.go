@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/itchyny/gojq"
 )
@@ -92,6 +93,9 @@ type tfstate struct {
 	TerraformVersion string         `json:"terraform_version"`
 	Serial           int            `json:"serial"`
 	Lineage          string         `json:"lineage"`
+
+	// Modules holds the resources and outputs of the state version 1 to 3.
+	Modules []moduleV3 `json:"modules"`
 }
 
 func outputValue(v any) any {
@@ -106,6 +110,8 @@ func outputValue(v any) any {
 type backend struct {
 	Type   string `json:"type"`
 	Config map[string]any
+
+	dir string // working directory of terraform, to resolve relative paths of the local backend
 }
 
 type resource struct {
@@ -126,6 +132,7 @@ type instance struct {
 	Attributes     any             `json:"attributes"`
 	AttributesFlat any             `json:"attributes_flat"`
 	Private        string          `json:"private"`
+	DependsOn      []string        `json:"depends_on,omitempty"`
 
 	data any
 }
@@ -149,6 +156,11 @@ func Read(ctx context.Context, src io.Reader) (*TFState, error) {
 
 // ReadWithWorkspace reads a tfstate from io.Reader with workspace
 func ReadWithWorkspace(ctx context.Context, src io.Reader, ws string) (*TFState, error) {
+	return readWithWorkspace(ctx, src, ws, "", nil)
+}
+
+// readWithWorkspace reads a tfstate. dir is the working directory of terraform, and cfg may be nil.
+func readWithWorkspace(ctx context.Context, src io.Reader, ws string, dir string, cfg *readURLConfig) (*TFState, error) {
 	if ws == "" {
 		ws = defaultWorkspace
 	}
@@ -156,14 +168,19 @@ func ReadWithWorkspace(ctx context.Context, src io.Reader, ws string) (*TFState,
 	if err := json.NewDecoder(src).Decode(&s.state); err != nil {
 		return nil, fmt.Errorf("invalid json: %w", err)
 	}
-	if s.state.Backend != nil {
-		remote, err := readRemoteState(ctx, s.state.Backend, ws)
+	// A state of version 3 may have a backend without type, which holds only the hash of the config.
+	if s.state.Backend != nil && s.state.Backend.Type != "" {
+		s.state.Backend.dir = dir
+		remote, err := readRemoteState(ctx, s.state.Backend, ws, cfg)
 		if err != nil {
 			return nil, err
 		}
 		defer remote.Close()
 		return Read(ctx, remote)
 	}
+	if s.state.Version >= 1 && s.state.Version < StateVersion {
+		s.state.upgradeV3()
+	}
 	if s.state.Version != StateVersion {
 		return nil, fmt.Errorf("unsupported state version %d", s.state.Version)
 	}
@@ -171,32 +188,76 @@ func ReadWithWorkspace(ctx context.Context, src io.Reader, ws string) (*TFState,
 }
 
 // ReadFile reads terraform.tfstate from the file
-// (Firstly, a workspace reads TF_WORKSPACE environment variable. if it doesn't exist, it reads from environment file in the same directory)
+// (Firstly, a workspace reads TF_WORKSPACE environment variable. if it doesn't exist, it reads from environment file in the same directory or .terraform directory)
+//
+// When the file is terraform.tfstate of the local backend and the workspace is not default,
+// it reads terraform.tfstate.d/{workspace}/terraform.tfstate instead.
 func ReadFile(ctx context.Context, file string) (*TFState, error) {
+	return readFile(ctx, file, nil)
+}
+
+func readFile(ctx context.Context, file string, cfg *readURLConfig) (*TFState, error) {
+	dir := filepath.Dir(file)
+	// working directory of terraform
+	workDir := dir
+	if filepath.Base(dir) == ".terraform" {
+		workDir = filepath.Dir(dir)
+	}
 	ws := func() string {
 		if env := os.Getenv("TF_WORKSPACE"); env != "" {
 			return env
 		}
-		f, _ := os.ReadFile(filepath.Join(filepath.Dir(file), "environment"))
-		return string(f)
-
+		for _, name := range []string{
+			filepath.Join(dir, "environment"),
+			filepath.Join(workDir, ".terraform", "environment"),
+		} {
+			if f, err := os.ReadFile(name); err == nil {
+				return strings.TrimSpace(string(f))
+			}
+		}
+		return ""
 	}()
+	if ws != "" && ws != defaultWorkspace && workDir == dir && filepath.Base(file) == defaultLocalStatePath {
+		wsFile := filepath.Join(dir, defaultLocalWorkspaceDir, ws, defaultLocalStatePath)
+		if _, err := os.Stat(wsFile); err != nil {
+			return nil, fmt.Errorf("failed to read tfstate of workspace %s: %w", ws, err)
+		}
+		file = wsFile
+	}
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read tfstate from %s: %w", file, err)
 	}
 	defer f.Close()
-	return ReadWithWorkspace(ctx, f, ws)
+	return readWithWorkspace(ctx, f, ws, workDir, cfg)
 }
 
 // readURLConfig holds internal configuration for ReadURL
 type readURLConfig struct {
-	s3Endpoint string
+	s3Endpoint       string
+	s3SSECustomerKey string
+	ossEndpoint      string
+	gcsEndpoint      string
+	azureRMEndpoint  string
+	ociEndpoint      string
+
+	// version selector of the state
+	versionID string
+	asOf      time.Time
+
+	// options of http(s) URLs
+	http httpOption
+
+	// statePull runs "terraform state pull" for unsupported backends
+	statePull *StatePullOption
 }
 
 func newReadURLConfig() *readURLConfig {
 	return &readURLConfig{
-		s3Endpoint: os.Getenv(S3EndpointEnvKey),
+		s3Endpoint:       os.Getenv(S3EndpointEnvKey),
+		s3SSECustomerKey: os.Getenv(S3SSECustomerKeyEnvKey),
+		azureRMEndpoint:  os.Getenv(AzureRMEndpointEnvKey),
+		http:             newHTTPOption(),
 	}
 }
 
@@ -214,6 +275,147 @@ func (o S3EndpointOption) applyReadURLConfig(c *readURLConfig) {
 	}
 }
 
+// S3SSECustomerKeyOption specifies the base64 encoded SSE-C key for S3 objects
+type S3SSECustomerKeyOption string
+
+func (o S3SSECustomerKeyOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.s3SSECustomerKey = string(o)
+	}
+}
+
+// VersionIDOption selects a version of the state by its ID (e.g. S3 object version ID, GCS generation, AzureRM blob version ID, TFE state version ID).
+// It takes precedence over the version selector in the URL query.
+type VersionIDOption string
+
+func (o VersionIDOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.versionID = string(o)
+	}
+}
+
+// AsOfOption selects the newest version of the state at or before the time.
+// It takes precedence over the version selector in the URL query.
+type AsOfOption time.Time
+
+func (o AsOfOption) applyReadURLConfig(c *readURLConfig) {
+	if t := time.Time(o); !t.IsZero() {
+		c.asOf = t
+	}
+}
+
+// GCSEndpointOption specifies the Google Cloud Storage endpoint URL (e.g. fake-gcs-server)
+type GCSEndpointOption string
+
+func (o GCSEndpointOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.gcsEndpoint = string(o)
+	}
+}
+
+// AzureRMEndpointOption specifies the Azure Blob Storage service URL (e.g. Azurite)
+type AzureRMEndpointOption string
+
+func (o AzureRMEndpointOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.azureRMEndpoint = string(o)
+	}
+}
+
+// OCIEndpointOption specifies the OCI Object Storage endpoint URL instead of the regional one
+type OCIEndpointOption string
+
+func (o OCIEndpointOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.ociEndpoint = string(o)
+	}
+}
+
+// HTTPBearerTokenOption specifies the bearer token of the Authorization header for http(s) URLs
+type HTTPBearerTokenOption string
+
+func (o HTTPBearerTokenOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.http.bearerToken = string(o)
+	}
+}
+
+// HTTPBasicAuthOption specifies the username and password of the basic authentication for http(s) URLs
+type HTTPBasicAuthOption struct {
+	Username string
+	Password string
+}
+
+func (o HTTPBasicAuthOption) applyReadURLConfig(c *readURLConfig) {
+	c.http.username = o.Username
+	c.http.password = o.Password
+	c.http.basicAuth = true
+}
+
+// HTTPHeaderOption adds a request header for http(s) URLs. It can be passed multiple times.
+type HTTPHeaderOption struct {
+	Name  string
+	Value string
+}
+
+func (o HTTPHeaderOption) applyReadURLConfig(c *readURLConfig) {
+	if o.Name != "" {
+		c.http.headers.Add(o.Name, o.Value)
+	}
+}
+
+// HTTPNetrcOption enables the credentials in the .netrc file ($NETRC or ~/.netrc) for http(s) URLs.
+// The credentials are not used when the Authorization header is specified by other options.
+type HTTPNetrcOption bool
+
+func (o HTTPNetrcOption) applyReadURLConfig(c *readURLConfig) {
+	c.http.netrc = bool(o)
+}
+
+// HTTPSigV4Option signs requests for http(s) URLs with AWS Signature Version 4 (e.g. API Gateway with IAM authorization).
+// The credentials are loaded by the default credential chain of AWS SDK. Region defaults to the region of the AWS config.
+type HTTPSigV4Option struct {
+	Region  string
+	Service string
+}
+
+func (o HTTPSigV4Option) applyReadURLConfig(c *readURLConfig) {
+	c.http.sigV4 = &o
+}
+
+// HTTPRetryOption specifies the retries of 5xx responses for http(s) URLs.
+// The wait before the n-th retry is WaitMin * 2^(n-1) (or Retry-After header), up to WaitMax.
+// http(s) URLs are not retried by default. WaitMin and WaitMax less than or equal to 0 are 1s and 30s.
+type HTTPRetryOption struct {
+	MaxRetries int
+	WaitMin    time.Duration
+	WaitMax    time.Duration
+}
+
+func (o HTTPRetryOption) applyReadURLConfig(c *readURLConfig) {
+	c.http.retry = o
+}
+
+// StatePullOption enables the fallback for a backend type which is not supported.
+// The state is read by running "{Binary} state pull" in the working directory of terraform.
+// Binary defaults to terraform, or tofu if terraform is not found in PATH.
+type StatePullOption struct {
+	Binary string
+}
+
+func (o StatePullOption) applyReadURLConfig(c *readURLConfig) {
+	c.statePull = &o
+}
+
+// OSSEndpointOption specifies the Alibaba Cloud OSS endpoint URL
+type OSSEndpointOption string
+
+func (o OSSEndpointOption) applyReadURLConfig(c *readURLConfig) {
+	if o != "" {
+		c.ossEndpoint = string(o)
+	}
+}
+
 // ReadURL reads terraform.tfstate from the URL.
 func ReadURL(ctx context.Context, loc string, opts ...ReadURLOption) (*TFState, error) {
 	u, err := url.Parse(loc)
@@ -221,41 +423,17 @@ func ReadURL(ctx context.Context, loc string, opts ...ReadURLOption) (*TFState,
 		return nil, err
 	}
 
-	var src io.ReadCloser
-	switch u.Scheme {
-	case "http", "https":
-		src, err = readHTTP(ctx, u.String())
-	case "s3":
-		cfg := newReadURLConfig()
-		for _, opt := range opts {
-			opt.applyReadURLConfig(cfg)
-		}
-		key := strings.TrimPrefix(u.Path, "/")
-		src, err = readS3(ctx, u.Host, key, S3Option{Endpoint: cfg.s3Endpoint})
-	case "gs":
-		key := strings.TrimPrefix(u.Path, "/")
-		src, err = readGCS(ctx, u.Host, key, "", os.Getenv("GOOGLE_ENCRYPTION_KEY"))
-	case "azurerm":
-		split := strings.SplitN(u.Path, "/", 4)
-
-		if len(split) < 4 {
-			err = fmt.Errorf("invalid azurerm url: %s", u.String())
-			break
-		}
+	cfg := newReadURLConfig()
+	for _, opt := range opts {
+		opt.applyReadURLConfig(cfg)
+	}
 
-		src, err = readAzureRM(ctx, u.Host, split[1], split[2], split[3], azureRMOption{subscriptionID: u.User.Username()})
-	case "file":
-		src, err = os.Open(u.Path)
-	case "remote":
-		split := strings.Split(u.Path, "/")
-		src, err = readTFE(ctx, u.Host, split[1], split[2], os.Getenv("TFE_TOKEN"))
-	case "":
-		return ReadFile(ctx, u.Path)
-	default:
-		err = fmt.Errorf("URL scheme %s is not supported", u.Scheme)
+	if u.Scheme == "" {
+		return readFile(ctx, u.Path, cfg)
 	}
+	src, err := readURLState(ctx, u, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tfstate from %s: %w", u.String(), err)
+		return nil, fmt.Errorf("failed to read tfstate from %s: %w", u.Redacted(), err)
 	}
 	defer src.Close()
 	return Read(ctx, src)
@@ -494,7 +672,7 @@ func (s *TFState) scanRegularResource(r resource, module, prefix string) {
 
 	// Handle single instance resource (most common case)
 	if len(r.Instances) == 1 && len(r.Instances[0].IndexKey) == 0 {
-		instanceData := noneNil(r.Instances[0].data, r.Instances[0].Attributes, r.Instances[0].AttributesFlat)
+		instanceData := r.Instances[0].attributes()
 		s.scanned[baseKey] = instanceData
 		return
 	}
@@ -505,7 +683,7 @@ func (s *TFState) scanRegularResource(r resource, module, prefix string) {
 
 	// Process all instances
 	for _, inst := range r.Instances {
-		instanceData := noneNil(inst.data, inst.Attributes, inst.AttributesFlat)
+		instanceData := inst.attributes()
 		iStr := string(inst.IndexKey)
 		key := baseKey + "[" + iStr + "]"
 		s.scanned[key] = instanceData
@@ -539,11 +717,17 @@ func (s *TFState) scanRegularResource(r resource, module, prefix string) {
 	}
 }
 
-func noneNil(args ...any) any {
-	for _, v := range args {
-		if v != nil {
-			return v
-		}
+// attributes returns the attributes of the instance.
+// attributes_flat of the legacy instances is converted to nested attributes.
+func (inst instance) attributes() any {
+	if inst.data != nil {
+		return inst.data
+	}
+	if inst.Attributes != nil {
+		return inst.Attributes
+	}
+	if inst.AttributesFlat != nil {
+		return unflattenAttributes(inst.AttributesFlat)
 	}
 	return nil
 }
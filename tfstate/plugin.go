@@ -0,0 +1,112 @@
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// PluginPrefix is the prefix of the executable names of external backends.
+// For a URL scheme or a backend type which is not registered, ReadURL and Read run
+// the executable named PluginPrefix + scheme (or type) found in PATH.
+//
+// The protocol is similar to git credential helpers:
+//
+//   - The request is written to stdin as a JSON object (PluginRequest).
+//   - On success, the executable writes the state to stdout and exits with status 0.
+//   - On failure, it writes the error message to stderr and exits with a non-zero status.
+//     Status PluginExitNotFound means that the state does not exist, and the error wraps fs.ErrNotExist.
+const PluginPrefix = "tfstate-lookup-backend-"
+
+// PluginExitNotFound is the exit status of external backends for a state which does not exist.
+const PluginExitNotFound = 3
+
+// PluginProtocolVersion is the version of PluginRequest.
+const PluginProtocolVersion = 1
+
+// PluginRequest is the request written to stdin of external backends.
+// URL is set for ReadURL, and Type, Config and Workspace are set for a backend of .terraform/terraform.tfstate.
+type PluginRequest struct {
+	Version   int            `json:"version"`
+	URL       string         `json:"url,omitempty"`
+	Type      string         `json:"type,omitempty"`
+	Config    map[string]any `json:"config,omitempty"`
+	Workspace string         `json:"workspace,omitempty"`
+}
+
+// pluginNameRegexp restricts the names of schemes and types to run as external backends.
+var pluginNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.+-]*$`)
+
+// lookupPlugin finds the executable of the external backend for the name in PATH.
+func lookupPlugin(name string) (string, bool) {
+	if !pluginNameRegexp.MatchString(name) {
+		return "", false
+	}
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// pluginBackend is a Backend which runs the executable of the external backend.
+type pluginBackend struct {
+	typ  string
+	path string
+}
+
+func (b pluginBackend) ReadState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	return runPlugin(ctx, b.path, PluginRequest{
+		Version:   PluginProtocolVersion,
+		Type:      b.typ,
+		Config:    config,
+		Workspace: ws,
+	})
+}
+
+// pluginScheme returns the URL scheme which sends the URL to the executable of the external backend as is.
+func pluginScheme(path string) urlScheme {
+	return urlScheme{
+		backend: BackendFunc(func(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+			return runPlugin(ctx, path, PluginRequest{
+				Version: PluginProtocolVersion,
+				URL:     *strpe(config["url"]),
+			})
+		}),
+		parse: func(u *url.URL) (map[string]any, string, error) {
+			return map[string]any{"url": u.String()}, "", nil
+		},
+	}
+}
+
+func runPlugin(ctx context.Context, path string, req PluginRequest) (io.ReadCloser, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == PluginExitNotFound {
+			return nil, fmt.Errorf("%s: %s: %w", path, msg, fs.ErrNotExist)
+		}
+		return nil, fmt.Errorf("%s: %s", path, msg)
+	}
+	return io.NopCloser(&stdout), nil
+}
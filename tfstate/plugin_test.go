@@ -0,0 +1,105 @@
+package tfstate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// installTestPlugin installs an external backend which saves the request into request.json
+// and writes test/terraform.tfstate.
+func installTestPlugin(t *testing.T, name string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not executable on windows")
+	}
+	state, err := filepath.Abs("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+req=$(cat)
+printf '%s' "$req" > "` + dir + `/request.json"
+case "$req" in
+  *missing*) echo "state is not found" >&2; exit 3;;
+  *broken*) echo "something is broken" >&2; exit 1;;
+esac
+cat "` + state + `"
+`
+	if err := os.WriteFile(filepath.Join(dir, tfstate.PluginPrefix+name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return filepath.Join(dir, "request.json")
+}
+
+func readPluginRequest(t *testing.T, path string) tfstate.PluginRequest {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req tfstate.PluginRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestReadPlugin(t *testing.T) {
+	reqFile := installTestPlugin(t, "testplugin")
+
+	t.Run("URL", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), "testplugin://store/network")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+		req := readPluginRequest(t, reqFile)
+		if req.Version != tfstate.PluginProtocolVersion || req.URL != "testplugin://store/network" || req.Type != "" {
+			t.Errorf("unexpected request %#v", req)
+		}
+	})
+
+	t.Run("backend", func(t *testing.T) {
+		src := `{"version": 3, "backend": {"type": "testplugin", "config": {"name": "network"}}}`
+		state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(src), "staging")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+		req := readPluginRequest(t, reqFile)
+		if req.Type != "testplugin" || req.Config["name"] != "network" || req.Workspace != "staging" || req.URL != "" {
+			t.Errorf("unexpected request %#v", req)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := tfstate.ReadURL(t.Context(), "testplugin://store/missing")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := tfstate.ReadURL(t.Context(), "testplugin://store/broken")
+		if err == nil || errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "something is broken") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("not installed", func(t *testing.T) {
+		_, err := tfstate.ReadURL(t.Context(), "noplugin://store/network")
+		if err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
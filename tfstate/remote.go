@@ -1,9 +1,10 @@
 package tfstate
 
 import (
-	"context"
-	"fmt"
-	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 func strp(v any) *string {
@@ -27,17 +28,81 @@ func strpe(v any) *string {
 	return &empty
 }
 
-func readRemoteState(ctx context.Context, b *backend, ws string) (io.ReadCloser, error) {
-	switch b.Type {
-	case "gcs":
-		return readGCSState(ctx, b.Config, ws)
-	case "azurerm":
-		return readAzureRMState(ctx, b.Config, ws)
-	case "s3":
-		return readS3State(ctx, b.Config, ws)
-	case "remote":
-		return readTFEState(ctx, b.Config, ws)
-	default:
-		return nil, fmt.Errorf("backend type %s is not supported", b.Type)
+// strs returns a list of strings from a config value.
+func strs(v any) []string {
+	vs, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	ss := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if s, ok := v.(string); ok {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// strm returns a map of strings from a config value.
+func strm(v any) map[string]string {
+	vm, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	m := make(map[string]string, len(vm))
+	for k, v := range vm {
+		if s, ok := v.(string); ok {
+			m[k] = s
+		}
+	}
+	return m
+}
+
+// configBlock returns a nested block of a config value.
+// A block may be stored as an object or as a list of a single object.
+func configBlock(v any) map[string]any {
+	switch b := v.(type) {
+	case map[string]any:
+		return b
+	case []any:
+		if len(b) > 0 {
+			if m, ok := b[0].(map[string]any); ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// envBool returns the boolean value of the environment variable.
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// firstEnv returns the first non-empty value of the environment variables.
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func expandHomeAll(paths []string) []string {
+	ps := make([]string, 0, len(paths))
+	for _, p := range paths {
+		ps = append(ps, expandHome(p))
+	}
+	return ps
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
 	}
+	return p
 }
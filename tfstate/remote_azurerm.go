@@ -4,25 +4,121 @@ package tfstate
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/go-autorest/autorest/azure/cli"
 )
 
+const AzureRMEndpointEnvKey = "AZURE_STORAGE_BLOB_ENDPOINT"
+
+// azureEnvironment represents a cloud of the environment setting of the azurerm backend.
+type azureEnvironment struct {
+	cloud         cloud.Configuration
+	storageSuffix string
+}
+
+var azureEnvironments = map[string]azureEnvironment{
+	"public":       {cloud: cloud.AzurePublic, storageSuffix: "core.windows.net"},
+	"china":        {cloud: cloud.AzureChina, storageSuffix: "core.chinacloudapi.cn"},
+	"usgovernment": {cloud: cloud.AzureGovernment, storageSuffix: "core.usgovcloudapi.net"},
+}
+
 type azureRMOption struct {
 	accessKey      string
-	useAzureAdAuth string
+	sasToken       string
+	useAzureAdAuth bool
 	subscriptionID string
+	environment    string
+	endpoint       string // blob service URL (e.g. Azurite)
+
+	tenantID                  string
+	clientID                  string
+	clientSecret              string
+	clientCertificatePath     string
+	clientCertificatePassword string
+	useMSI                    bool
+	msiEndpoint               string
+	useOIDC                   bool
+	oidcToken                 string
+	oidcTokenFilePath         string
+	oidcRequestURL            string
+	oidcRequestToken          string
+
+	// snapshot or versionID selects a snapshot or a version of the state blob.
+	// asOf selects the newest one at or before the time when both are empty.
+	snapshot  string
+	versionID string
+	asOf      time.Time
+}
+
+func newAzureRMOption() *azureRMOption {
+	return &azureRMOption{
+		accessKey:                 os.Getenv("ARM_ACCESS_KEY"),
+		sasToken:                  os.Getenv("ARM_SAS_TOKEN"),
+		useAzureAdAuth:            envBool("ARM_USE_AZUREAD"),
+		subscriptionID:            os.Getenv("ARM_SUBSCRIPTION_ID"),
+		environment:               os.Getenv("ARM_ENVIRONMENT"),
+		endpoint:                  os.Getenv(AzureRMEndpointEnvKey),
+		tenantID:                  os.Getenv("ARM_TENANT_ID"),
+		clientID:                  os.Getenv("ARM_CLIENT_ID"),
+		clientSecret:              os.Getenv("ARM_CLIENT_SECRET"),
+		clientCertificatePath:     os.Getenv("ARM_CLIENT_CERTIFICATE_PATH"),
+		clientCertificatePassword: os.Getenv("ARM_CLIENT_CERTIFICATE_PASSWORD"),
+		useMSI:                    envBool("ARM_USE_MSI"),
+		msiEndpoint:               os.Getenv("ARM_MSI_ENDPOINT"),
+		useOIDC:                   envBool("ARM_USE_OIDC"),
+		oidcToken:                 os.Getenv("ARM_OIDC_TOKEN"),
+		oidcTokenFilePath:         os.Getenv("ARM_OIDC_TOKEN_FILE_PATH"),
+		oidcRequestURL:            firstEnv("ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"),
+		oidcRequestToken:          firstEnv("ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"),
+	}
 }
 
 func readAzureRMState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
-	accountName, containerName, key := *strp(config["storage_account_name"]), *strpe(config["container_name"]), *strpe(config["key"])
-	resourceGroupName := *strp(config["resource_group_name"])
+	b, opt, err := azureRMStateBlob(ctx, config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return readAzureRM(ctx, b.resourceGroupName, b.accountName, b.containerName, b.key, *opt)
+}
+
+func listAzureRMStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	b, opt, err := azureRMStateBlob(ctx, config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return listAzureRMVersions(ctx, b.resourceGroupName, b.accountName, b.containerName, b.key, *opt)
+}
+
+// azureRMBlob is the blob of the state.
+type azureRMBlob struct {
+	resourceGroupName string
+	accountName       string
+	containerName     string
+	key               string
+}
+
+// azureRMStateBlob returns the blob and the option of the state of the azurerm backend.
+func azureRMStateBlob(ctx context.Context, config map[string]any, ws string) (azureRMBlob, *azureRMOption, error) {
+	accountName, containerName, key := *strpe(config["storage_account_name"]), *strpe(config["container_name"]), *strpe(config["key"])
+	resourceGroupName := *strpe(config["resource_group_name"])
 	if ws != defaultWorkspace {
 		if prefix := strp(config["workspace_key_prefix"]); prefix != nil {
 			key = key + *prefix + ws
@@ -30,36 +126,220 @@ func readAzureRMState(ctx context.Context, config map[string]any, ws string) (io
 			key = key + defaultWorkspaceKeyPrefix + ws
 		}
 	}
-	opt := azureRMOption{
-		accessKey:      *strpe(config["access_key"]),
-		useAzureAdAuth: *strpe(config["use_azuread_auth"]),
-		subscriptionID: *strpe(config["subscription_id"]),
+	opt := newAzureRMOption()
+	if cfg := readURLConfigFrom(ctx); cfg != nil && cfg.azureRMEndpoint != "" {
+		opt.endpoint = cfg.azureRMEndpoint
+	}
+	for k, p := range map[string]*string{
+		"access_key":                  &opt.accessKey,
+		"sas_token":                   &opt.sasToken,
+		"subscription_id":             &opt.subscriptionID,
+		"environment":                 &opt.environment,
+		"tenant_id":                   &opt.tenantID,
+		"client_id":                   &opt.clientID,
+		"client_secret":               &opt.clientSecret,
+		"client_certificate_path":     &opt.clientCertificatePath,
+		"client_certificate_password": &opt.clientCertificatePassword,
+		"msi_endpoint":                &opt.msiEndpoint,
+		"oidc_token":                  &opt.oidcToken,
+		"oidc_token_file_path":        &opt.oidcTokenFilePath,
+		"oidc_request_url":            &opt.oidcRequestURL,
+		"oidc_request_token":          &opt.oidcRequestToken,
+	} {
+		if v := *strpe(config[k]); v != "" {
+			*p = v
+		}
+	}
+	for k, p := range map[string]*bool{
+		"use_azuread_auth": &opt.useAzureAdAuth,
+		"use_msi":          &opt.useMSI,
+		"use_oidc":         &opt.useOIDC,
+	} {
+		switch v := config[k].(type) {
+		case bool:
+			*p = v
+		case string: // older versions of the state
+			*p = v == "true"
+		}
+	}
+	versionID, asOf, err := stateVersion(ctx, config)
+	if err != nil {
+		return azureRMBlob{}, nil, err
 	}
-	return readAzureRM(ctx, resourceGroupName, accountName, containerName, key, opt)
+	opt.snapshot, opt.versionID, opt.asOf = *strpe(config["snapshot"]), versionID, asOf
+	return azureRMBlob{resourceGroupName, accountName, containerName, key}, opt, nil
 }
 
 func readAzureRM(ctx context.Context, resourceGroupName string, accountName string, containerName string, key string, opt azureRMOption) (io.ReadCloser, error) {
-	serviceUrl := fmt.Sprintf("https://%s.blob.core.windows.net/", accountName)
+	if opt.snapshot != "" && opt.versionID != "" {
+		return nil, fmt.Errorf("snapshot and versionid can't be specified at the same time")
+	}
+	client, err := newAzureBlobClient(ctx, resourceGroupName, accountName, opt)
+	if err != nil {
+		return nil, err
+	}
 
-	var client *azblob.Client
+	v := StateVersionInfo{VersionID: opt.versionID}
+	if opt.snapshot != "" {
+		v = StateVersionInfo{VersionID: opt.snapshot, Snapshot: true}
+	} else if opt.versionID == "" && !opt.asOf.IsZero() {
+		if v, err = findAzureBlobVersionAt(ctx, client, containerName, key, opt.asOf); err != nil {
+			return nil, err
+		}
+	}
+	return downloadAzureBlob(ctx, client, containerName, key, v, 0)
+}
+
+// listAzureRMVersions lists the snapshots and versions of the state blob, newest first.
+func listAzureRMVersions(ctx context.Context, resourceGroupName, accountName, containerName, key string, opt azureRMOption) ([]StateVersionInfo, error) {
+	client, err := newAzureBlobClient(ctx, resourceGroupName, accountName, opt)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := azureBlobVersions(ctx, client, containerName, key)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range versions {
+		serial, err := readVersionSerial(func(size int64) (io.ReadCloser, error) {
+			return downloadAzureBlob(ctx, client, containerName, key, v, size)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", v.VersionID, err)
+		}
+		versions[i].Serial = serial
+	}
+	return versions, nil
+}
+
+// findAzureBlobVersionAt returns the newest snapshot or version at or before t.
+func findAzureBlobVersionAt(ctx context.Context, client *azblob.Client, containerName, key string, t time.Time) (StateVersionInfo, error) {
+	versions, err := azureBlobVersions(ctx, client, containerName, key)
+	if err != nil {
+		return StateVersionInfo{}, err
+	}
+	for _, v := range versions {
+		if !v.LastModified.After(t) {
+			return v, nil
+		}
+	}
+	return StateVersionInfo{}, fmt.Errorf("no snapshot or version of %s/%s at or before %s", containerName, key, t.Format(time.RFC3339))
+}
+
+// azureBlobVersions returns the snapshots and versions of the blob, newest first.
+// The current blob without versioning is returned with an empty VersionID.
+func azureBlobVersions(ctx context.Context, client *azblob.Client, containerName, key string) ([]StateVersionInfo, error) {
+	var versions []StateVersionInfo
+	pager := client.NewListBlobsFlatPager(containerName, &azblob.ListBlobsFlatOptions{
+		Prefix:  &key,
+		Include: azblob.ListBlobsInclude{Snapshots: true, Versions: true},
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil || *item.Name != key {
+				continue
+			}
+			var v StateVersionInfo
+			switch {
+			case item.Snapshot != nil && *item.Snapshot != "":
+				v = StateVersionInfo{VersionID: *item.Snapshot, Snapshot: true}
+			case item.VersionID != nil && *item.VersionID != "":
+				v = StateVersionInfo{VersionID: *item.VersionID, IsLatest: item.IsCurrentVersion != nil && *item.IsCurrentVersion}
+			default:
+				v = StateVersionInfo{IsLatest: true}
+			}
+			if item.Properties != nil && item.Properties.LastModified != nil {
+				v.LastModified = *item.Properties.LastModified
+			}
+			versions = append(versions, v)
+		}
+	}
+	// versions come first for the same time, as the content of snapshots is the same.
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].LastModified.Equal(versions[j].LastModified) {
+			return !versions[i].Snapshot && versions[j].Snapshot
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+// downloadAzureBlob downloads the first size bytes of the snapshot or the version of the blob, or the whole of it when size is 0.
+func downloadAzureBlob(ctx context.Context, client *azblob.Client, containerName, key string, v StateVersionInfo, size int64) (io.ReadCloser, error) {
+	blobClient := client.ServiceClient().NewContainerClient(containerName).NewBlobClient(key)
+	var err error
+	switch {
+	case v.Snapshot:
+		blobClient, err = blobClient.WithSnapshot(v.VersionID)
+	case v.VersionID != "":
+		blobClient, err = blobClient.WithVersionID(v.VersionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var opts *blob.DownloadStreamOptions
+	if size > 0 {
+		opts = &blob.DownloadStreamOptions{Range: blob.HTTPRange{Count: size}}
+	}
+	blobDownloadResponse, err := blobClient.DownloadStream(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob: %w", err)
+	}
 
-	if opt.useAzureAdAuth == "true" || os.Getenv("ARM_USE_AZUREAD") == "true" {
-		cred, err := getDefaultAzureCredential()
+	r := blobDownloadResponse.Body
+	return r, nil
+}
+
+func newAzureBlobClient(ctx context.Context, resourceGroupName string, accountName string, opt azureRMOption) (*azblob.Client, error) {
+	env, err := opt.azureEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	serviceUrl := opt.endpoint
+	if serviceUrl == "" {
+		serviceUrl = fmt.Sprintf("https://%s.blob.%s/", accountName, env.storageSuffix)
+	}
+	clientOpts := &azblob.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud: env.cloud,
+			// allow a local emulator such as Azurite over http
+			InsecureAllowCredentialWithHTTP: strings.HasPrefix(serviceUrl, "http://"),
+		},
+	}
+
+	var client *azblob.Client
+	switch {
+	case opt.sasToken != "":
+		u, err := url.Parse(serviceUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blob service url: %w", err)
+		}
+		u.RawQuery = strings.TrimPrefix(opt.sasToken, "?")
+		client, err = azblob.NewClientWithNoCredential(u.String(), clientOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup client: %w", err)
+		}
+	case opt.useAzureAdAuth:
+		cred, err := newAzureTokenCredential(opt, env)
 		if err != nil {
 			return nil, err
 		}
 
-		client, err = azblob.NewClient(serviceUrl, cred, nil)
+		client, err = azblob.NewClient(serviceUrl, cred, clientOpts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to setup client: %w", err)
 		}
-	} else {
+	default:
 		// get blob access key
 		var accountKey string
 		for _, gen := range []func() (string, error){
 			func() (string, error) { return opt.accessKey, nil },
 			func() (string, error) { return os.Getenv("AZURE_STORAGE_ACCESS_KEY"), nil },
-			func() (string, error) { return getDefaultAzureAccessKey(ctx, resourceGroupName, accountName, opt) },
+			func() (string, error) { return getDefaultAzureAccessKey(ctx, resourceGroupName, accountName, opt, env) },
 		} {
 			key, err := gen()
 			if err != nil {
@@ -79,19 +359,154 @@ func readAzureRM(ctx context.Context, resourceGroupName string, accountName stri
 			return nil, fmt.Errorf("failed to create credential: %w", err)
 		}
 
-		client, err = azblob.NewClientWithSharedKeyCredential(serviceUrl, credential, nil)
+		client, err = azblob.NewClientWithSharedKeyCredential(serviceUrl, credential, clientOpts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to setup client: %w", err)
 		}
 	}
+	return client, nil
+}
+
+func (opt azureRMOption) azureEnvironment() (azureEnvironment, error) {
+	name := strings.ToLower(opt.environment)
+	if name == "" {
+		name = "public"
+	}
+	env, ok := azureEnvironments[name]
+	if !ok {
+		return azureEnvironment{}, fmt.Errorf("unsupported azure environment: %s", opt.environment)
+	}
+	return env, nil
+}
 
-	blobDownloadResponse, err := client.DownloadStream(ctx, containerName, key, nil)
+// newAzureTokenCredential returns a credential in the same order as the azurerm backend of Terraform:
+// OIDC, client certificate, client secret, managed identity, and then the default credential chain.
+func newAzureTokenCredential(opt azureRMOption, env azureEnvironment) (azcore.TokenCredential, error) {
+	clientOpts := azcore.ClientOptions{Cloud: env.cloud}
+	var cred azcore.TokenCredential
+	var err error
+	switch {
+	case opt.useOIDC:
+		cred, err = azidentity.NewClientAssertionCredential(opt.tenantID, opt.clientID, opt.getOIDCToken,
+			&azidentity.ClientAssertionCredentialOptions{ClientOptions: clientOpts})
+	case opt.clientCertificatePath != "" && opt.clientID != "" && opt.tenantID != "":
+		b, rerr := os.ReadFile(opt.clientCertificatePath)
+		if rerr != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", rerr)
+		}
+		certs, key, perr := azidentity.ParseCertificates(b, []byte(opt.clientCertificatePassword))
+		if perr != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %w", perr)
+		}
+		cred, err = azidentity.NewClientCertificateCredential(opt.tenantID, opt.clientID, certs, key,
+			&azidentity.ClientCertificateCredentialOptions{ClientOptions: clientOpts})
+	case opt.clientSecret != "" && opt.clientID != "" && opt.tenantID != "":
+		cred, err = azidentity.NewClientSecretCredential(opt.tenantID, opt.clientID, opt.clientSecret,
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOpts})
+	case opt.useMSI && opt.msiEndpoint != "":
+		cred = &azureMSICredential{endpoint: opt.msiEndpoint, clientID: opt.clientID}
+	case opt.useMSI:
+		var id azidentity.ManagedIDKind
+		if opt.clientID != "" {
+			id = azidentity.ClientID(opt.clientID)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOpts, ID: id})
+	default:
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: clientOpts})
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to download blob: %w", err)
+		return nil, fmt.Errorf("failed to authorize: %w", err)
 	}
+	return cred, nil
+}
 
-	r := blobDownloadResponse.Body
-	return r, nil
+// getOIDCToken returns an ID token for the workload identity federation.
+func (opt azureRMOption) getOIDCToken(ctx context.Context) (string, error) {
+	switch {
+	case opt.oidcToken != "":
+		return opt.oidcToken, nil
+	case opt.oidcTokenFilePath != "":
+		b, err := os.ReadFile(opt.oidcTokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read oidc token file: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	case opt.oidcRequestURL != "" && opt.oidcRequestToken != "":
+		// GitHub Actions ID token
+		u, err := url.Parse(opt.oidcRequestURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid oidc request url: %w", err)
+		}
+		q := u.Query()
+		q.Set("audience", "api://AzureADTokenExchange")
+		u.RawQuery = q.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+opt.oidcRequestToken)
+		var res struct {
+			Value string `json:"value"`
+		}
+		if err := azureGetJSON(req, &res); err != nil {
+			return "", fmt.Errorf("failed to request oidc token: %w", err)
+		}
+		return res.Value, nil
+	}
+	return "", fmt.Errorf("use_oidc requires oidc_token, oidc_token_file_path or oidc_request_url with oidc_request_token")
+}
+
+// azureMSICredential gets a token from the managed identity endpoint specified by msi_endpoint.
+type azureMSICredential struct {
+	endpoint string
+	clientID string
+}
+
+func (c *azureMSICredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(opts.Scopes) == 0 {
+		return azcore.AccessToken{}, fmt.Errorf("no scope is requested")
+	}
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("invalid msi_endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("api-version", "2018-02-01")
+	q.Set("resource", strings.TrimSuffix(opts.Scopes[0], "/.default"))
+	if c.clientID != "" {
+		q.Set("client_id", c.clientID)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	req.Header.Set("Metadata", "true")
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := azureGetJSON(req, &res); err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("failed to get token from msi_endpoint: %w", err)
+	}
+	expiresOn := time.Now().Add(time.Hour)
+	if sec, err := strconv.ParseInt(res.ExpiresOn, 10, 64); err == nil {
+		expiresOn = time.Unix(sec, 0)
+	}
+	return azcore.AccessToken{Token: res.AccessToken, ExpiresOn: expiresOn}, nil
+}
+
+func azureGetJSON(req *http.Request, v any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
 }
 
 func getDefaultAzureSubscription() (string, error) {
@@ -114,8 +529,8 @@ func getDefaultAzureSubscription() (string, error) {
 	return subscriptionID, nil
 }
 
-func getDefaultAzureAccessKey(ctx context.Context, resourceGroupName string, accountName string, opt azureRMOption) (string, error) {
-	cred, err := getDefaultAzureCredential()
+func getDefaultAzureAccessKey(ctx context.Context, resourceGroupName string, accountName string, opt azureRMOption, env azureEnvironment) (string, error) {
+	cred, err := newAzureTokenCredential(opt, env)
 	if err != nil {
 		return "", err
 	}
@@ -125,7 +540,9 @@ func getDefaultAzureAccessKey(ctx context.Context, resourceGroupName string, acc
 		return "", err
 	}
 
-	clientFactory, err := armstorage.NewClientFactory(subscriptionID, cred, nil)
+	clientFactory, err := armstorage.NewClientFactory(subscriptionID, cred, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: env.cloud},
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to create client factory: %w", err)
 	}
@@ -149,11 +566,3 @@ func getAzureSubscription(opt azureRMOption) (string, error) {
 
 	return subscriptionID, nil
 }
-
-func getDefaultAzureCredential() (*azidentity.DefaultAzureCredential, error) {
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to authorize: %w", err)
-	}
-	return cred, nil
-}
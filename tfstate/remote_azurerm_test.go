@@ -0,0 +1,253 @@
+//go:build !no_azurerm
+
+package tfstate_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// the well-known account of Azurite
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// newAzureBlobServer returns a fake Blob service like Azurite.
+// authorized reports whether the request is authorized.
+func newAzureBlobServer(t *testing.T, authorized func(*http.Request) bool, blobs map[string][]byte) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.Header().Set("x-ms-error-code", "AuthenticationFailed")
+			http.Error(w, "AuthenticationFailed", http.StatusForbidden)
+			return
+		}
+		b, ok := blobs[strings.TrimPrefix(r.URL.Path, "/"+azuriteAccount+"/")]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			http.Error(w, "BlobNotFound", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(b)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.Write(b)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestReadAzureRMBackend(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := map[string][]byte{
+		"tfstate/prod.terraform.tfstate":            b,
+		"tfstate/prod.terraform.tfstateenv:staging": b,
+	}
+	for _, k := range []string{"ARM_ACCESS_KEY", "ARM_SAS_TOKEN", "ARM_USE_AZUREAD", "ARM_USE_MSI", "ARM_MSI_ENDPOINT", "ARM_USE_OIDC", "ARM_CLIENT_ID", "ARM_CLIENT_SECRET", "ARM_TENANT_ID", "ARM_ENVIRONMENT", "AZURE_STORAGE_ACCESS_KEY"} {
+		t.Setenv(k, "")
+	}
+	backend := func(config string) string {
+		return fmt.Sprintf(`{"version": 3, "backend": {"type": "azurerm", "config": {"storage_account_name": %q, "container_name": "tfstate", "key": "prod.terraform.tfstate", %s}}}`, azuriteAccount, config)
+	}
+
+	t.Run("access_key", func(t *testing.T) {
+		ts := newAzureBlobServer(t, func(r *http.Request) bool {
+			return strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey "+azuriteAccount+":")
+		}, blobs)
+		t.Setenv(tfstate.AzureRMEndpointEnvKey, ts.URL+"/"+azuriteAccount)
+		for _, ws := range []string{"default", "staging"} {
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(backend(fmt.Sprintf(`"access_key": %q`, azuriteKey))), ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		}
+	})
+
+	t.Run("sas_token", func(t *testing.T) {
+		ts := newAzureBlobServer(t, func(r *http.Request) bool {
+			return r.URL.Query().Get("sig") == "signature" && r.Header.Get("Authorization") == ""
+		}, blobs)
+		t.Setenv(tfstate.AzureRMEndpointEnvKey, ts.URL+"/"+azuriteAccount)
+		state, err := tfstate.Read(t.Context(), strings.NewReader(backend(`"sas_token": "?sv=2022-11-02&sp=r&sig=signature"`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("use_msi with msi_endpoint", func(t *testing.T) {
+		msi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "https://storage.azure.com" || r.URL.Query().Get("client_id") != "my-client" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "msi-token", "expires_on": "4102444800"})
+		}))
+		defer msi.Close()
+		ts := newAzureBlobServer(t, func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer msi-token"
+		}, blobs)
+		t.Setenv(tfstate.AzureRMEndpointEnvKey, ts.URL+"/"+azuriteAccount)
+		state, err := tfstate.Read(t.Context(), strings.NewReader(backend(fmt.Sprintf(`"use_azuread_auth": true, "use_msi": true, "client_id": "my-client", "msi_endpoint": %q`, msi.URL))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("URL with AzureRMEndpointOption", func(t *testing.T) {
+		ts := newAzureBlobServer(t, func(r *http.Request) bool {
+			return strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey "+azuriteAccount+":")
+		}, blobs)
+		t.Setenv(tfstate.AzureRMEndpointEnvKey, "")
+		t.Setenv("ARM_ACCESS_KEY", azuriteKey)
+		state, err := tfstate.ReadURL(t.Context(), "azurerm://rg/"+azuriteAccount+"/tfstate/prod.terraform.tfstate",
+			tfstate.AzureRMEndpointOption(ts.URL+"/"+azuriteAccount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("unsupported environment", func(t *testing.T) {
+		src := backend(fmt.Sprintf(`"access_key": %q, "environment": "german"`, base64.StdEncoding.EncodeToString([]byte("key"))))
+		if _, err := tfstate.Read(t.Context(), strings.NewReader(src)); err == nil {
+			t.Error("expected error for an unsupported environment")
+		}
+	})
+}
+
+// newVersionedAzureBlobServer returns a fake Blob service with snapshots and blob versioning.
+func newVersionedAzureBlobServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	const (
+		v1 = "2026-01-01T00:00:00.0000000Z"
+		s1 = "2026-01-01T12:00:00.0000000Z"
+		v2 = "2026-01-02T00:00:00.0000000Z"
+	)
+	states := map[string]string{
+		"versionid=" + v1: `{"version": 4, "serial": 1, "outputs": {"ws": {"value": "v1", "type": "string"}}}`,
+		"snapshot=" + s1:  `{"version": 4, "serial": 1, "outputs": {"ws": {"value": "s1", "type": "string"}}}`,
+		"versionid=" + v2: `{"version": 4, "serial": 2, "outputs": {"ws": {"value": "v2", "type": "string"}}}`,
+		"":                `{"version": 4, "serial": 2, "outputs": {"ws": {"value": "v2", "type": "string"}}}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/" + azuriteAccount + "/tfstate":
+			if q.Get("comp") != "list" || q.Get("include") != "snapshots,versions" {
+				http.Error(w, "InvalidQueryParameterValue", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="tfstate"><Prefix>%s</Prefix><Blobs>`, q.Get("prefix"))
+			fmt.Fprintf(w, `<Blob><Name>prod.terraform.tfstate</Name><VersionId>%s</VersionId><Properties><Last-Modified>Thu, 01 Jan 2026 00:00:00 GMT</Last-Modified></Properties></Blob>`, v1)
+			fmt.Fprintf(w, `<Blob><Name>prod.terraform.tfstate</Name><Snapshot>%s</Snapshot><Properties><Last-Modified>Thu, 01 Jan 2026 00:00:00 GMT</Last-Modified></Properties></Blob>`, s1)
+			fmt.Fprintf(w, `<Blob><Name>prod.terraform.tfstate</Name><VersionId>%s</VersionId><IsCurrentVersion>true</IsCurrentVersion><Properties><Last-Modified>Fri, 02 Jan 2026 00:00:00 GMT</Last-Modified></Properties></Blob>`, v2)
+			fmt.Fprint(w, `<Blob><Name>prod.terraform.tfstateenv:staging</Name><Properties><Last-Modified>Fri, 02 Jan 2026 00:00:00 GMT</Last-Modified></Properties></Blob>`)
+			fmt.Fprint(w, `</Blobs><NextMarker/></EnumerationResults>`)
+		case "/" + azuriteAccount + "/tfstate/prod.terraform.tfstate":
+			var selector string
+			for _, k := range []string{"snapshot", "versionid"} {
+				if v := q.Get(k); v != "" {
+					selector = k + "=" + v
+				}
+			}
+			s, ok := states[selector]
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", fmt.Sprint(len(s)))
+			w.Header().Set("x-ms-blob-type", "BlockBlob")
+			fmt.Fprint(w, s)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestReadAzureRMVersions(t *testing.T) {
+	ts := newVersionedAzureBlobServer(t)
+	for _, k := range []string{"ARM_SAS_TOKEN", "ARM_USE_AZUREAD", "ARM_ENVIRONMENT"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("ARM_ACCESS_KEY", azuriteKey)
+	t.Setenv(tfstate.AzureRMEndpointEnvKey, ts.URL+"/"+azuriteAccount)
+	base := "azurerm://rg/" + azuriteAccount + "/tfstate/prod.terraform.tfstate"
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     []tfstate.ReadURLOption
+		expected string
+	}{
+		{"latest", base, nil, "v2"},
+		{"versionid", base + "?versionid=2026-01-01T00:00:00.0000000Z", nil, "v1"},
+		{"snapshot", base + "?snapshot=2026-01-01T12:00:00.0000000Z", nil, "s1"},
+		{"asOf", base + "?asOf=2026-01-01T18:00:00Z", nil, "v1"},
+		{"VersionIDOption", base, []tfstate.ReadURLOption{tfstate.VersionIDOption("2026-01-01T00:00:00.0000000Z")}, "v1"},
+		{"AsOfOption", base, []tfstate.ReadURLOption{tfstate.AsOfOption(time.Date(2026, 1, 2, 1, 0, 0, 0, time.UTC))}, "v2"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := tfstate.ReadURL(t.Context(), tc.url, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj, err := state.Lookup("output.ws")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.String() != tc.expected {
+				t.Errorf("unexpected version %s, expected %s", obj.String(), tc.expected)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		base + "?asOf=2025-12-31T00:00:00Z",
+		base + "?snapshot=2026-01-01T12:00:00.0000000Z&versionid=2026-01-01T00:00:00.0000000Z",
+	} {
+		if _, err := tfstate.ReadURL(t.Context(), u); err == nil {
+			t.Errorf("expected error for %s", u)
+		}
+	}
+
+	t.Run("ListStateVersions", func(t *testing.T) {
+		versions, err := tfstate.ListStateVersions(t.Context(), base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []tfstate.StateVersionInfo{
+			{VersionID: "2026-01-02T00:00:00.0000000Z", Serial: 2, LastModified: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), IsLatest: true},
+			{VersionID: "2026-01-01T00:00:00.0000000Z", Serial: 1, LastModified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{VersionID: "2026-01-01T12:00:00.0000000Z", Serial: 1, LastModified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Snapshot: true},
+		}
+		if len(versions) != len(expected) {
+			t.Fatalf("unexpected versions %v", versions)
+		}
+		for i, v := range versions {
+			e := expected[i]
+			if v.VersionID != e.VersionID || v.Serial != e.Serial || !v.LastModified.Equal(e.LastModified) || v.IsLatest != e.IsLatest || v.Snapshot != e.Snapshot {
+				t.Errorf("unexpected version %#v, expected %#v", v, e)
+			}
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package tfstate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	defaultConsulAddress = "127.0.0.1:8500"
+	consulKeyEnvPrefix   = "-env:"
+)
+
+type consulOption struct {
+	address    string
+	scheme     string
+	token      string
+	datacenter string
+	httpAuth   string
+	caFile     string
+	certFile   string
+	keyFile    string
+}
+
+func newConsulOption() *consulOption {
+	opt := &consulOption{
+		address:  os.Getenv("CONSUL_HTTP_ADDR"),
+		scheme:   "http",
+		token:    os.Getenv("CONSUL_HTTP_TOKEN"),
+		httpAuth: os.Getenv("CONSUL_HTTP_AUTH"),
+		caFile:   os.Getenv("CONSUL_CACERT"),
+		certFile: os.Getenv("CONSUL_CLIENT_CERT"),
+		keyFile:  os.Getenv("CONSUL_CLIENT_KEY"),
+	}
+	if opt.address == "" {
+		opt.address = defaultConsulAddress
+	}
+	if os.Getenv("CONSUL_HTTP_SSL") == "true" {
+		opt.scheme = "https"
+	}
+	return opt
+}
+
+func readConsulState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	key := *strpe(config["path"])
+	if key == "" {
+		return nil, fmt.Errorf("consul backend requires path")
+	}
+	if ws != defaultWorkspace {
+		key = key + consulKeyEnvPrefix + ws
+	}
+	opt := newConsulOption()
+	for k, p := range map[string]*string{
+		"address":      &opt.address,
+		"scheme":       &opt.scheme,
+		"access_token": &opt.token,
+		"datacenter":   &opt.datacenter,
+		"http_auth":    &opt.httpAuth,
+		"ca_file":      &opt.caFile,
+		"cert_file":    &opt.certFile,
+		"key_file":     &opt.keyFile,
+	} {
+		if v := *strpe(config[k]); v != "" {
+			*p = v
+		}
+	}
+	return readConsul(ctx, key, *opt)
+}
+
+func readConsul(ctx context.Context, key string, opt consulOption) (io.ReadCloser, error) {
+	// the address may have the scheme as Terraform accepts (e.g. https://consul.example.com:8501)
+	if scheme, addr, ok := strings.Cut(opt.address, "://"); ok {
+		switch scheme {
+		case "https":
+			opt.scheme = scheme
+		case "http":
+		default:
+			return nil, fmt.Errorf("unsupported scheme of consul address: %s", opt.address)
+		}
+		opt.address = addr
+	}
+	hopt := httpBackendOption{}
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{
+		{opt.caFile, &hopt.caCertificatePEM},
+		{opt.certFile, &hopt.clientCertificatePEM},
+		{opt.keyFile, &hopt.clientPrivateKeyPEM},
+	} {
+		if f.name == "" {
+			continue
+		}
+		b, err := os.ReadFile(f.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.name, err)
+		}
+		*f.dst = string(b)
+	}
+	client, err := newHTTPBackendClient(hopt)
+	if err != nil {
+		return nil, err
+	}
+	get := func(key string) ([]byte, error) {
+		return getConsulKV(ctx, client, key, opt)
+	}
+
+	value, err := get(key)
+	if err != nil {
+		return nil, err
+	}
+	payload := value
+	hash, chunks, chunked := consulChunks(value)
+	if chunked {
+		payload = nil
+		for _, c := range chunks {
+			v, err := get(c)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read chunk %s: %w", c, err)
+			}
+			payload = append(payload, v...)
+		}
+	}
+	// gzip compressed state starts with the gzip magic number, not json
+	if len(payload) > 0 && payload[0] == 0x1f {
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+		if payload, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+	}
+	if hash != "" && fmt.Sprintf("%x", md5.Sum(payload)) != hash {
+		return nil, fmt.Errorf("the state stored in consul %s does not match the expected hash", key)
+	}
+	return io.NopCloser(bytes.NewReader(payload)), nil
+}
+
+// consulChunks returns the hash and chunk keys when the value is a chunked state index.
+func consulChunks(value []byte) (string, []string, bool) {
+	var index struct {
+		Hash   *string  `json:"current-hash"`
+		Chunks []string `json:"chunks"`
+	}
+	if err := json.Unmarshal(value, &index); err != nil || index.Hash == nil {
+		return "", nil, false
+	}
+	return *index.Hash, index.Chunks, true
+}
+
+func getConsulKV(ctx context.Context, client *http.Client, key string, opt consulOption) ([]byte, error) {
+	u := &url.URL{
+		Scheme: opt.scheme,
+		Host:   opt.address,
+		Path:   "/v1/kv/" + strings.TrimPrefix(key, "/"),
+	}
+	q := url.Values{}
+	q.Set("raw", "")
+	if opt.datacenter != "" {
+		q.Set("dc", opt.datacenter)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if opt.token != "" {
+		req.Header.Set("X-Consul-Token", opt.token)
+	}
+	if user, pass, ok := strings.Cut(opt.httpAuth, ":"); ok {
+		req.SetBasicAuth(user, pass)
+	} else if opt.httpAuth != "" {
+		req.SetBasicAuth(opt.httpAuth, "")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("consul key %s: %w", key, fs.ErrNotExist)
+	default:
+		return nil, fmt.Errorf("failed to get consul key %s: %s", key, resp.Status)
+	}
+}
@@ -0,0 +1,145 @@
+package tfstate_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func newConsulServer(t *testing.T, token string, kv map[string][]byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(consulHandler(token, kv))
+}
+
+func consulHandler(token string, kv map[string][]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		v, ok := kv[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(v)
+	})
+}
+
+func TestReadConsul(t *testing.T) {
+	// each workspace has a distinct state to check the workspace suffix
+	b := []byte(localTestState("staging"))
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(b)
+	zw.Close()
+	compressed := gz.Bytes()
+	half := len(compressed) / 2
+	index, _ := json.Marshal(map[string]any{
+		"current-hash": fmt.Sprintf("%x", md5.Sum(b)),
+		"chunks":       []string{"tfstate/app-env:staging/tfstate.0", "tfstate/app-env:staging/tfstate.1"},
+	})
+	kv := map[string][]byte{
+		"tfstate/app":                       []byte(localTestState("default")),
+		"tfstate/app-env:staging":           index,
+		"tfstate/app-env:staging/tfstate.0": compressed[:half],
+		"tfstate/app-env:staging/tfstate.1": compressed[half:],
+	}
+	ts := newConsulServer(t, "secret", kv)
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	t.Setenv("CONSUL_HTTP_TOKEN", "secret")
+
+	backend := fmt.Sprintf(`{
+  "version": 3,
+  "backend": {
+    "type": "consul",
+    "config": {"address": "%s", "scheme": "http", "path": "tfstate/app"}
+  }
+}`, u.Host)
+
+	t.Run("default workspace", func(t *testing.T) {
+		state, err := tfstate.Read(t.Context(), strings.NewReader(backend))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "default" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("chunked and gzipped workspace", func(t *testing.T) {
+		state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(backend), "staging")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "staging" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), "consul://"+u.Host+"/tfstate/app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "default" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("CONSUL_HTTP_ADDR with scheme", func(t *testing.T) {
+		t.Setenv("CONSUL_HTTP_ADDR", ts.URL)
+		src := `{"version": 3, "backend": {"type": "consul", "config": {"path": "tfstate/app"}}}`
+		state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "default" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("https address", func(t *testing.T) {
+		tls := httptest.NewTLSServer(consulHandler("secret", kv))
+		defer tls.Close()
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		writeTestFile(t, caFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tls.Certificate().Raw})))
+		src := fmt.Sprintf(`{"version": 3, "backend": {"type": "consul", "config": {"address": "%s", "path": "tfstate/app", "ca_file": "%s"}}}`, tls.URL, caFile)
+		state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "default" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := tfstate.ReadURL(t.Context(), "consul://"+u.Host+"/tfstate/missing")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist for a missing key, got %v", err)
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		src := `{"version": 3, "backend": {"type": "consul", "config": {"address": "unix:///var/run/consul.sock", "path": "tfstate/app"}}}`
+		if _, err := tfstate.Read(t.Context(), strings.NewReader(src)); err == nil {
+			t.Error("expected error for an unsupported scheme")
+		}
+	})
+}
@@ -5,53 +5,281 @@ package tfstate
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
+	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+type gcsOption struct {
+	credentials                        string // path or contents of the credentials JSON
+	accessToken                        string
+	impersonateServiceAccount          string
+	impersonateServiceAccountDelegates []string
+	endpoint                           string
+	encryptionKey                      string // path or contents of the base64 encoded key
+
+	// generation selects a generation of the state object.
+	// asOf selects the newest generation at or before the time when generation is 0.
+	generation int64
+	asOf       time.Time
+}
+
+func newGCSOption() *gcsOption {
+	return &gcsOption{
+		credentials:               firstEnv("GOOGLE_BACKEND_CREDENTIALS", "GOOGLE_CREDENTIALS"),
+		accessToken:               os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		impersonateServiceAccount: firstEnv("GOOGLE_BACKEND_IMPERSONATE_SERVICE_ACCOUNT", "GOOGLE_IMPERSONATE_SERVICE_ACCOUNT"),
+		endpoint:                  firstEnv("GOOGLE_BACKEND_STORAGE_CUSTOM_ENDPOINT", "GOOGLE_STORAGE_CUSTOM_ENDPOINT"),
+		encryptionKey:             os.Getenv("GOOGLE_ENCRYPTION_KEY"),
+	}
+}
+
 func readGCSState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
-	bucket := *strp(config["bucket"])
-	prefix := *strpe(config["prefix"])
-	credentials := *strpe(config["credentials"])
-	encryption_key := *strpe(config["encryption_key"])
+	bucket, key, opt, err := gcsStateObject(ctx, config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return readGCS(ctx, bucket, key, *opt)
+}
+
+func listGCSStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	bucket, key, opt, err := gcsStateObject(ctx, config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return listGCSGenerations(ctx, bucket, key, *opt)
+}
 
+// gcsStateObject returns the bucket, the key and the option of the state object of the gcs backend.
+func gcsStateObject(ctx context.Context, config map[string]any, ws string) (string, string, *gcsOption, error) {
+	bucket := *strpe(config["bucket"])
+	prefix := *strpe(config["prefix"])
 	key := path.Join(prefix, ws+".tfstate")
+	if k := *strpe(config["key"]); k != "" {
+		// the object of gs:// URL
+		key = k
+	}
+
+	opt := newGCSOption()
+	if cfg := readURLConfigFrom(ctx); cfg != nil && cfg.gcsEndpoint != "" {
+		opt.endpoint = cfg.gcsEndpoint
+	}
+	for k, p := range map[string]*string{
+		"credentials":                 &opt.credentials,
+		"access_token":                &opt.accessToken,
+		"impersonate_service_account": &opt.impersonateServiceAccount,
+		"storage_custom_endpoint":     &opt.endpoint,
+		"encryption_key":              &opt.encryptionKey,
+	} {
+		if v := *strpe(config[k]); v != "" {
+			*p = v
+		}
+	}
+	opt.impersonateServiceAccountDelegates = strs(config["impersonate_service_account_delegates"])
+	versionID, asOf, err := stateVersion(ctx, config)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if versionID != "" {
+		g, err := strconv.ParseInt(versionID, 10, 64)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid generation %q: %w", versionID, err)
+		}
+		opt.generation = g
+	}
+	opt.asOf = asOf
+	return bucket, key, opt, nil
+}
+
+func readGCS(ctx context.Context, bucket, key string, opt gcsOption) (io.ReadCloser, error) {
+	client, err := newGCSClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	generation := opt.generation
+	if generation == 0 && !opt.asOf.IsZero() {
+		if generation, err = findGCSGenerationAt(ctx, client, bucket, key, opt.asOf); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := gcsObject(client, bucket, key, generation, opt)
+	if err != nil {
+		return nil, err
+	}
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
 
-	return readGCS(ctx, bucket, key, credentials, encryption_key)
+// listGCSGenerations lists the generations of the state object, newest first.
+// LastModified of the versions is the time when the generation was created.
+func listGCSGenerations(ctx context.Context, bucket, key string, opt gcsOption) ([]StateVersionInfo, error) {
+	client, err := newGCSClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	generations, err := gcsGenerations(ctx, client, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]StateVersionInfo, 0, len(generations))
+	for _, attrs := range generations {
+		obj, err := gcsObject(client, bucket, key, attrs.Generation, opt)
+		if err != nil {
+			return nil, err
+		}
+		serial, err := readVersionSerial(func(size int64) (io.ReadCloser, error) {
+			if size > 0 {
+				return obj.NewRangeReader(ctx, 0, size)
+			}
+			return obj.NewReader(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read generation %d: %w", attrs.Generation, err)
+		}
+		versions = append(versions, StateVersionInfo{
+			VersionID:    strconv.FormatInt(attrs.Generation, 10),
+			Serial:       serial,
+			LastModified: attrs.Created,
+			IsLatest:     attrs.Deleted.IsZero(),
+		})
+	}
+	return versions, nil
 }
 
-func readGCS(ctx context.Context, bucket, key, credentials, encryption_key string) (io.ReadCloser, error) {
-	var err error
+// findGCSGenerationAt returns the newest generation at or before t.
+func findGCSGenerationAt(ctx context.Context, client *storage.Client, bucket, key string, t time.Time) (int64, error) {
+	generations, err := gcsGenerations(ctx, client, bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	for _, attrs := range generations {
+		if attrs.Created.After(t) {
+			continue
+		}
+		if !attrs.Deleted.IsZero() && !attrs.Deleted.After(t) {
+			return 0, fmt.Errorf("gs://%s/%s was deleted at %s", bucket, key, attrs.Deleted.Format(time.RFC3339))
+		}
+		return attrs.Generation, nil
+	}
+	return 0, fmt.Errorf("no generation of gs://%s/%s at or before %s", bucket, key, t.Format(time.RFC3339))
+}
 
-	var client *storage.Client
-	if credentials != "" {
-		client, err = storage.NewClient(ctx, option.WithCredentialsFile(credentials))
-	} else {
-		client, err = storage.NewClient(ctx)
+// gcsGenerations returns all generations of the object, newest first.
+func gcsGenerations(ctx context.Context, client *storage.Client, bucket, key string) ([]*storage.ObjectAttrs, error) {
+	var generations []*storage.ObjectAttrs
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: key, Versions: true})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list generations: %w", err)
+		}
+		if attrs.Name == key {
+			generations = append(generations, attrs)
+		}
 	}
+	sort.SliceStable(generations, func(i, j int) bool {
+		return generations[i].Generation > generations[j].Generation
+	})
+	return generations, nil
+}
 
+func newGCSClient(ctx context.Context, opt gcsOption) (*storage.Client, error) {
+	opts, err := newGCSClientOptions(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
+	return storage.NewClient(ctx, opts...)
+}
 
-	bkt := client.Bucket(bucket)
-	obj := bkt.Object(key)
+func gcsObject(client *storage.Client, bucket, key string, generation int64, opt gcsOption) (*storage.ObjectHandle, error) {
+	obj := client.Bucket(bucket).Object(key)
+	if generation != 0 {
+		obj = obj.Generation(generation)
+	}
+	if opt.encryptionKey != "" {
+		kc, err := readPathOrContents(opt.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load encryption key: %w", err)
+		}
+		decodedKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(kc))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+		}
+		obj = obj.Key(decodedKey)
+	}
+	return obj, nil
+}
 
-	var r *storage.Reader
+// newGCSClientOptions builds client options in the same way as the gcs backend of Terraform.
+func newGCSClientOptions(ctx context.Context, opt gcsOption) ([]option.ClientOption, error) {
+	var opts, credOpts []option.ClientOption
+	if opt.accessToken != "" {
+		credOpts = append(credOpts, option.WithTokenSource(
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: opt.accessToken}),
+		))
+	} else if opt.credentials != "" {
+		// accepts the file path or the contents
+		contents, err := readPathOrContents(opt.credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credentials: %w", err)
+		}
+		var c struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(contents), &c); err != nil {
+			return nil, fmt.Errorf("the credentials is neither valid json nor a valid file path")
+		}
+		credOpts = append(credOpts, option.WithAuthCredentialsJSON(option.CredentialsType(c.Type), []byte(contents)))
+	}
 
-	if encryption_key != "" {
-		decodedKey, _ := base64.StdEncoding.DecodeString(encryption_key)
-		r, err = obj.Key(decodedKey).NewReader(ctx)
+	if opt.impersonateServiceAccount != "" {
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: opt.impersonateServiceAccount,
+			Scopes:          []string{storage.ScopeReadOnly},
+			Delegates:       opt.impersonateServiceAccountDelegates,
+		}, credOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to impersonate service account: %w", err)
+		}
+		opts = append(opts, option.WithTokenSource(ts))
 	} else {
-		r, err = obj.NewReader(ctx)
+		opts = append(opts, credOpts...)
 	}
 
-	if err != nil {
-		return nil, err
+	if opt.endpoint != "" {
+		opts = append(opts, option.WithEndpoint(opt.endpoint))
 	}
+	return opts, nil
+}
 
-	return r, nil
+// readPathOrContents returns the contents of the file if the argument is a path,
+// otherwise returns the argument as is.
+func readPathOrContents(poc string) (string, error) {
+	if poc == "" {
+		return poc, nil
+	}
+	p := expandHome(poc)
+	if _, err := os.Stat(p); err == nil {
+		b, err := os.ReadFile(p)
+		return string(b), err
+	}
+	return poc, nil
 }
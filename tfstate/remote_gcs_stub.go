@@ -12,6 +12,6 @@ func readGCSState(ctx context.Context, config map[string]any, ws string) (io.Rea
 	return nil, fmt.Errorf("GCS backend is not available (built with no_gcs tag)")
 }
 
-func readGCS(ctx context.Context, bucket, key, credentials, encryption_key string) (io.ReadCloser, error) {
+func listGCSStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
 	return nil, fmt.Errorf("GCS backend is not available (built with no_gcs tag)")
 }
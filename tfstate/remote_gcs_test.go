@@ -0,0 +1,228 @@
+//go:build !no_gcs
+
+package tfstate_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// newGCSServer returns a fake GCS server like fake-gcs-server.
+// It serves objects only to requests with the token when the token is not empty.
+func newGCSServer(t *testing.T, bucket, token string, objects map[string][]byte) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, `{"error": {"code": 401, "message": "unauthorized"}}`, http.StatusUnauthorized)
+			return
+		}
+		// objects are read by XML API (/{bucket}/{object})
+		b, ok := objects[strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")]
+		if !ok {
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestReadGCSBackend(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := map[string][]byte{
+		"states/default.tfstate": b,
+		"states/staging.tfstate": b,
+	}
+	for _, k := range []string{"GOOGLE_BACKEND_CREDENTIALS", "GOOGLE_CREDENTIALS", "GOOGLE_OAUTH_ACCESS_TOKEN", "GOOGLE_BACKEND_IMPERSONATE_SERVICE_ACCOUNT", "GOOGLE_IMPERSONATE_SERVICE_ACCOUNT", "GOOGLE_BACKEND_STORAGE_CUSTOM_ENDPOINT", "GOOGLE_STORAGE_CUSTOM_ENDPOINT", "GOOGLE_ENCRYPTION_KEY", "STORAGE_EMULATOR_HOST"} {
+		t.Setenv(k, "")
+	}
+
+	t.Run("access_token and storage_custom_endpoint", func(t *testing.T) {
+		ts := newGCSServer(t, "mybucket", "token-config", objects)
+		for _, ws := range []string{"default", "staging"} {
+			src := fmt.Sprintf(`{"version": 3, "backend": {"type": "gcs", "config": {"bucket": "mybucket", "prefix": "states", "access_token": "token-config", "storage_custom_endpoint": "%s/storage/v1/"}}}`, ts.URL)
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(src), ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		}
+	})
+
+	t.Run("GOOGLE_OAUTH_ACCESS_TOKEN", func(t *testing.T) {
+		ts := newGCSServer(t, "mybucket", "token-env", objects)
+		t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "token-env")
+		t.Setenv("GOOGLE_BACKEND_STORAGE_CUSTOM_ENDPOINT", ts.URL+"/storage/v1/")
+		src := `{"version": 3, "backend": {"type": "gcs", "config": {"bucket": "mybucket", "prefix": "states"}}}`
+		state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("URL with GCSEndpointOption", func(t *testing.T) {
+		ts := newGCSServer(t, "mybucket", "token-env", objects)
+		t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "token-env")
+		state, err := tfstate.ReadURL(t.Context(), "gs://mybucket/states/default.tfstate", tfstate.GCSEndpointOption(ts.URL+"/storage/v1/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("STORAGE_EMULATOR_HOST", func(t *testing.T) {
+		ts := newGCSServer(t, "mybucket", "", objects)
+		t.Setenv("STORAGE_EMULATOR_HOST", ts.URL)
+		state, err := tfstate.ReadURL(t.Context(), "gs://mybucket/states/staging.tfstate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		src := `{"version": 3, "backend": {"type": "gcs", "config": {"bucket": "mybucket", "credentials": "not-a-json-nor-file"}}}`
+		if _, err := tfstate.Read(t.Context(), strings.NewReader(src)); err == nil {
+			t.Error("expected error for invalid credentials")
+		}
+	})
+}
+
+// newVersionedGCSServer returns a fake GCS server with object versioning.
+func newVersionedGCSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	generations := []struct {
+		generation           int
+		created, timeDeleted string
+	}{
+		{3, "2026-01-03T00:00:00Z", ""},
+		{2, "2026-01-02T00:00:00Z", "2026-01-03T00:00:00Z"},
+		{1, "2026-01-01T00:00:00Z", "2026-01-01T12:00:00Z"},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/storage/v1/b/mybucket/o":
+			if q.Get("versions") != "true" {
+				http.Error(w, "versions must be listed", http.StatusBadRequest)
+				return
+			}
+			items := []map[string]any{
+				{"name": "terraform.tfstate.backup", "bucket": "mybucket", "generation": "9", "timeCreated": "2026-01-02T00:00:00Z"},
+			}
+			for _, g := range generations {
+				item := map[string]any{
+					"name":        "terraform.tfstate",
+					"bucket":      "mybucket",
+					"generation":  fmt.Sprint(g.generation),
+					"timeCreated": g.created,
+					"updated":     g.created,
+				}
+				if g.timeDeleted != "" {
+					item["timeDeleted"] = g.timeDeleted
+				}
+				items = append(items, item)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"kind": "storage#objects", "items": items})
+		case "/mybucket/terraform.tfstate":
+			g := q.Get("generation")
+			if g == "" {
+				g = "3"
+			}
+			for _, v := range generations {
+				if fmt.Sprint(v.generation) == g {
+					fmt.Fprintf(w, `{"version": 4, "serial": %d, "outputs": {"ws": {"value": "g%d", "type": "string"}}}`, v.generation, v.generation)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestReadGCSGenerations(t *testing.T) {
+	ts := newVersionedGCSServer(t)
+	t.Setenv("STORAGE_EMULATOR_HOST", ts.URL)
+	t.Setenv("GOOGLE_ENCRYPTION_KEY", "")
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     []tfstate.ReadURLOption
+		expected string
+	}{
+		{"latest", "gs://mybucket/terraform.tfstate", nil, "g3"},
+		{"fragment", "gs://mybucket/terraform.tfstate#1", nil, "g1"},
+		{"generation query", "gs://mybucket/terraform.tfstate?generation=2", nil, "g2"},
+		{"asOf", "gs://mybucket/terraform.tfstate?asOf=2026-01-02T12:00:00Z", nil, "g2"},
+		{"VersionIDOption", "gs://mybucket/terraform.tfstate#1", []tfstate.ReadURLOption{tfstate.VersionIDOption("2")}, "g2"},
+		{"AsOfOption", "gs://mybucket/terraform.tfstate", []tfstate.ReadURLOption{tfstate.AsOfOption(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC))}, "g1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := tfstate.ReadURL(t.Context(), tc.url, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj, err := state.Lookup("output.ws")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.String() != tc.expected {
+				t.Errorf("unexpected generation %s, expected %s", obj.String(), tc.expected)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"gs://mybucket/terraform.tfstate?asOf=2025-12-31T00:00:00Z", // before the first generation
+		"gs://mybucket/terraform.tfstate?asOf=2026-01-01T18:00:00Z", // deleted
+		"gs://mybucket/terraform.tfstate#latest",
+	} {
+		if _, err := tfstate.ReadURL(t.Context(), u); err == nil {
+			t.Errorf("expected error for %s", u)
+		}
+	}
+
+	t.Run("ListStateVersions", func(t *testing.T) {
+		versions, err := tfstate.ListStateVersions(t.Context(), "gs://mybucket/terraform.tfstate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 3 {
+			t.Fatalf("unexpected versions %v", versions)
+		}
+		for i, v := range versions {
+			if expected := fmt.Sprint(3 - i); v.VersionID != expected {
+				t.Errorf("unexpected generation %s, expected %s", v.VersionID, expected)
+			}
+			if v.Serial != int64(3-i) {
+				t.Errorf("unexpected serial %d of %s", v.Serial, v.VersionID)
+			}
+			if v.IsLatest != (i == 0) {
+				t.Errorf("unexpected is_latest %t of %s", v.IsLatest, v.VersionID)
+			}
+		}
+		if !versions[1].LastModified.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected last modified %s", versions[1].LastModified)
+		}
+	})
+}
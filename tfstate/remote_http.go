@@ -1,26 +1,394 @@
 package tfstate
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func readHTTP(ctx context.Context, u string) (io.ReadCloser, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+type httpBackendOption struct {
+	username             string
+	password             string
+	headers              map[string]string
+	skipCertVerification bool
+	caCertificatePEM     string
+	clientCertificatePEM string
+	clientPrivateKeyPEM  string
+	retry                HTTPRetryOption
+}
+
+func readHTTPState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	if ws != defaultWorkspace {
+		return nil, fmt.Errorf("http backend does not support workspaces: %s", ws)
+	}
+	address := httpBackendConfig(config, "address", "TF_HTTP_ADDRESS")
+	if address == "" {
+		return nil, fmt.Errorf("http backend requires address")
+	}
+	if cfg := readURLConfigFrom(ctx); cfg != nil {
+		// the options of ReadURL are used for http(s) URLs instead of the backend config and TF_HTTP_* environment variables
+		return readHTTP(ctx, address, cfg.http)
+	}
+	opt := httpBackendOption{
+		username:             httpBackendConfig(config, "username", "TF_HTTP_USERNAME"),
+		password:             httpBackendConfig(config, "password", "TF_HTTP_PASSWORD"),
+		skipCertVerification: config["skip_cert_verification"] == true,
+		caCertificatePEM:     httpBackendConfig(config, "client_ca_certificate_pem", "TF_HTTP_CLIENT_CA_CERTIFICATE_PEM"),
+		clientCertificatePEM: httpBackendConfig(config, "client_certificate_pem", "TF_HTTP_CLIENT_CERTIFICATE_PEM"),
+		clientPrivateKeyPEM:  httpBackendConfig(config, "client_private_key_pem", "TF_HTTP_CLIENT_PRIVATE_KEY_PEM"),
+		retry:                defaultHTTPRetry,
+	}
+	if hs, ok := config["headers"].(map[string]any); ok {
+		opt.headers = make(map[string]string, len(hs))
+		for k, v := range hs {
+			opt.headers[k] = *strpe(v)
+		}
+	}
+	for key, p := range map[string]*int{
+		"retry_max": &opt.retry.MaxRetries,
+	} {
+		if err := httpBackendInt(config, key, "TF_HTTP_"+strings.ToUpper(key), p); err != nil {
+			return nil, err
+		}
+	}
+	for key, p := range map[string]*time.Duration{
+		"retry_wait_min": &opt.retry.WaitMin,
+		"retry_wait_max": &opt.retry.WaitMax,
+	} {
+		var sec int
+		if err := httpBackendInt(config, key, "TF_HTTP_"+strings.ToUpper(key), &sec); err != nil {
+			return nil, err
+		}
+		if sec > 0 {
+			*p = time.Duration(sec) * time.Second
+		}
+	}
+	return readHTTPBackend(ctx, address, opt)
+}
+
+// httpBackendConfig returns the value of the backend config key, or the environment variable if the key is not set.
+func httpBackendConfig(config map[string]any, key, envKey string) string {
+	if v := *strpe(config[key]); v != "" {
+		return v
+	}
+	return os.Getenv(envKey)
+}
+
+// httpBackendInt sets the integer value of the backend config key, or the environment variable if the key is not set.
+func httpBackendInt(config map[string]any, key, envKey string, p *int) error {
+	if v, ok := config[key].(float64); ok {
+		*p = int(v)
+		return nil
+	}
+	s := httpBackendConfig(config, key, envKey)
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, s, err)
+	}
+	*p = v
+	return nil
+}
+
+func readHTTPBackend(ctx context.Context, address string, opt httpBackendOption) (io.ReadCloser, error) {
+	client, err := newHTTPBackendClient(opt)
 	if err != nil {
 		return nil, err
 	}
-	return readHTTPWithRequest(ctx, req)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range opt.headers {
+		req.Header.Set(k, v)
+	}
+	if opt.username != "" || opt.password != "" {
+		req.SetBasicAuth(opt.username, opt.password)
+	}
+	return readHTTPWithClient(ctx, client, req, opt.retry, nil)
 }
 
-func readHTTPWithRequest(ctx context.Context, req *http.Request) (io.ReadCloser, error) {
-	if c := req.Context(); c != ctx {
-		req = req.WithContext(ctx)
+func newHTTPBackendClient(opt httpBackendOption) (*http.Client, error) {
+	if !opt.skipCertVerification && opt.caCertificatePEM == "" && opt.clientCertificatePEM == "" {
+		return http.DefaultClient, nil
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opt.skipCertVerification,
+	}
+	if opt.caCertificatePEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(opt.caCertificatePEM)) {
+			return nil, fmt.Errorf("failed to parse client_ca_certificate_pem")
+		}
+		tlsConfig.RootCAs = pool
 	}
-	resp, err := http.DefaultClient.Do(req)
+	if opt.clientCertificatePEM != "" {
+		if opt.clientPrivateKeyPEM == "" {
+			return nil, fmt.Errorf("client_private_key_pem is required with client_certificate_pem")
+		}
+		cert, err := tls.X509KeyPair([]byte(opt.clientCertificatePEM), []byte(opt.clientPrivateKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// httpOption is the options of requests for http(s) URLs.
+type httpOption struct {
+	bearerToken string
+	username    string
+	password    string
+	basicAuth   bool
+	headers     http.Header
+	netrc       bool
+	sigV4       *HTTPSigV4Option
+	retry       HTTPRetryOption
+}
+
+func newHTTPOption() httpOption {
+	return httpOption{
+		headers: make(http.Header),
+	}
+}
+
+func readHTTP(ctx context.Context, u string, opt httpOption) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, err
 	}
-	return resp.Body, nil
+	for k, vs := range opt.headers {
+		req.Header[k] = vs
+	}
+	switch {
+	case opt.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+opt.bearerToken)
+	case opt.basicAuth:
+		req.SetBasicAuth(opt.username, opt.password)
+	case opt.netrc && req.Header.Get("Authorization") == "" && req.URL.User == nil:
+		login, password, err := netrcCredentials(netrcPath(), req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		if login != "" || password != "" {
+			req.SetBasicAuth(login, password)
+		}
+	}
+
+	var sign func(context.Context, *http.Request) error
+	if opt.sigV4 != nil {
+		if sign, err = newHTTPSigV4Signer(ctx, *opt.sigV4); err != nil {
+			return nil, err
+		}
+	}
+	return readHTTPWithClient(ctx, http.DefaultClient, req, opt.retry, sign)
+}
+
+// readHTTPWithClient sends the request and returns the body of the successful response.
+// A response of 5xx is retried with the exponential backoff, and other unsuccessful responses are returned as *HTTPStatusError.
+// sign is called for each attempt if it is not nil.
+func readHTTPWithClient(ctx context.Context, client *http.Client, req *http.Request, retry HTTPRetryOption, sign func(context.Context, *http.Request) error) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		r := req.Clone(ctx)
+		if sign != nil {
+			if err := sign(ctx, r); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := client.Do(r)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp.Body, nil
+		}
+		statusErr := newHTTPStatusError(resp)
+		if resp.StatusCode < 500 || attempt >= retry.MaxRetries {
+			return nil, statusErr
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry.wait(attempt, resp)):
+		}
+	}
+}
+
+// HTTPStatusError is returned when a http(s) URL or the http backend responds with an unsuccessful status.
+// errors.Is reports fs.ErrNotExist for 404 and fs.ErrPermission for 401 and 403.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// Body is the beginning of the response body.
+	Body string
+}
+
+// httpStatusErrorBodyLimit is the max length of HTTPStatusError.Body.
+const httpStatusErrorBodyLimit = 512
+
+func newHTTPStatusError(resp *http.Response) *HTTPStatusError {
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, httpStatusErrorBodyLimit))
+	return &HTTPStatusError{
+		URL:        resp.Request.URL.Redacted(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(b)),
+	}
+}
+
+func (e *HTTPStatusError) Error() string {
+	msg := fmt.Sprintf("unexpected HTTP status %s from %s", e.Status, e.URL)
+	if line, _, _ := strings.Cut(e.Body, "\n"); line != "" {
+		msg += ": " + line
+	}
+	return msg
+}
+
+func (e *HTTPStatusError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.StatusCode == http.StatusNotFound
+	case fs.ErrPermission:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// defaultHTTPRetry is the same as the defaults of the http backend of Terraform.
+var defaultHTTPRetry = HTTPRetryOption{
+	MaxRetries: 2,
+	WaitMin:    time.Second,
+	WaitMax:    30 * time.Second,
+}
+
+// wait returns the duration to wait before the next attempt.
+// WaitMin and WaitMax default to 1s and 30s as Terraform, and Retry-After header in seconds is respected up to WaitMax.
+func (o HTTPRetryOption) wait(attempt int, resp *http.Response) time.Duration {
+	waitMin, waitMax := o.WaitMin, o.WaitMax
+	if waitMin <= 0 {
+		waitMin = defaultHTTPRetry.WaitMin
+	}
+	if waitMax <= 0 {
+		waitMax = defaultHTTPRetry.WaitMax
+	}
+	if sec, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64); err == nil && sec >= 0 {
+		if sec > int64(waitMax/time.Second) {
+			return waitMax
+		}
+		return min(time.Duration(sec)*time.Second, waitMax)
+	}
+	// compare before shifting not to overflow
+	if waitMin > waitMax>>attempt {
+		return waitMax
+	}
+	return waitMin << attempt
+}
+
+// netrcPath returns the path of .netrc file ($NETRC or ~/.netrc).
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	return expandHome("~/.netrc")
+}
+
+// netrcCredentials returns the login and password for the host in the .netrc file.
+// The default entry is used when no machine matches. A missing file is not an error.
+func netrcCredentials(path string, host string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" {
+				fields = fields[:i]
+				inMacro = true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	type entry struct{ login, password string }
+	var found, def *entry
+	var current *entry
+	for i := 0; i < len(tokens); i++ {
+		next := func() string {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+			return ""
+		}
+		switch tokens[i] {
+		case "machine":
+			current = nil
+			if strings.EqualFold(next(), host) && found == nil {
+				found = &entry{}
+				current = found
+			}
+		case "default":
+			current = nil
+			if def == nil {
+				def = &entry{}
+				current = def
+			}
+		case "login":
+			if v := next(); current != nil {
+				current.login = v
+			}
+		case "password":
+			if v := next(); current != nil {
+				current.password = v
+			}
+		case "account":
+			next()
+		}
+	}
+	if found == nil {
+		found = def
+	}
+	if found == nil {
+		return "", "", nil
+	}
+	return found.login, found.password, nil
 }
@@ -0,0 +1,51 @@
+//go:build !no_s3
+
+package tfstate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty body of GET requests.
+var emptyPayloadHash = func() string {
+	h := sha256.Sum256(nil)
+	return hex.EncodeToString(h[:])
+}()
+
+func newHTTPSigV4Signer(ctx context.Context, opt HTTPSigV4Option) (func(context.Context, *http.Request) error, error) {
+	if opt.Service == "" {
+		return nil, fmt.Errorf("service is required for AWS Signature Version 4")
+	}
+	var optFns []func(*config.LoadOptions) error
+	if opt.Region != "" {
+		optFns = append(optFns, config.WithRegion(opt.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("region is required for AWS Signature Version 4")
+	}
+	creds := aws.NewCredentialsCache(cfg.Credentials)
+	signer := v4.NewSigner()
+	return func(ctx context.Context, req *http.Request) error {
+		c, err := creds.Retrieve(ctx)
+		if err != nil {
+			return err
+		}
+		if opt.Service == "s3" {
+			req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+		}
+		return signer.SignHTTP(ctx, c, req, emptyPayloadHash, opt.Service, cfg.Region, time.Now())
+	}, nil
+}
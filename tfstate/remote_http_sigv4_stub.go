@@ -0,0 +1,13 @@
+//go:build no_s3
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+func newHTTPSigV4Signer(ctx context.Context, opt HTTPSigV4Option) (func(context.Context, *http.Request) error, error) {
+	return nil, fmt.Errorf("AWS Signature Version 4 is not available (built with no_s3 tag)")
+}
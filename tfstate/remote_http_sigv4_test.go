@@ -0,0 +1,41 @@
+//go:build !no_s3
+
+package tfstate_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadHTTPSigV4(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			!strings.Contains(auth, "/ap-northeast-1/execute-api/aws4_request") ||
+			r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Security-Token") != "session-token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session-token")
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+
+	state, err := tfstate.ReadURL(t.Context(), ts.URL+"/terraform.tfstate", tfstate.HTTPSigV4Option{Service: "execute-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+
+	if _, err := tfstate.ReadURL(t.Context(), ts.URL+"/terraform.tfstate", tfstate.HTTPSigV4Option{}); err == nil {
+		t.Error("expected error without service")
+	}
+}
@@ -0,0 +1,251 @@
+package tfstate_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadHTTPBackend(t *testing.T) {
+	h := http.FileServer(http.Dir("."))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Custom") != "custom-value" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	t.Setenv("TF_HTTP_PASSWORD", "pass")
+	src := fmt.Sprintf(`{
+  "version": 3,
+  "backend": {
+    "type": "http",
+    "config": {
+      "address": "%s/test/terraform.tfstate",
+      "username": "user",
+      "headers": {"X-Custom": "custom-value"}
+    }
+  }
+}`, ts.URL)
+	state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadHTTPStatus(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts < 3 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			http.ServeFile(w, r, "test/terraform.tfstate")
+		case "/down":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		case "/forbidden":
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			http.Error(w, "no such state", http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	retry := tfstate.HTTPRetryOption{MaxRetries: 2, WaitMin: time.Millisecond, WaitMax: 10 * time.Millisecond}
+
+	t.Run("retry", func(t *testing.T) {
+		attempts = 0
+		state, err := tfstate.ReadURL(t.Context(), ts.URL+"/flaky", retry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+		if attempts != 3 {
+			t.Errorf("unexpected attempts %d", attempts)
+		}
+	})
+
+	t.Run("no retry by default", func(t *testing.T) {
+		attempts = 0
+		if _, err := tfstate.ReadURL(t.Context(), ts.URL+"/down"); err == nil {
+			t.Fatal("expected error")
+		}
+		if attempts != 1 {
+			t.Errorf("unexpected attempts %d", attempts)
+		}
+	})
+
+	tests := []struct {
+		path     string
+		status   int
+		is       error
+		attempts int
+	}{
+		{"/down", http.StatusInternalServerError, nil, 3},
+		{"/forbidden", http.StatusForbidden, fs.ErrPermission, 1},
+		{"/missing", http.StatusNotFound, fs.ErrNotExist, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			attempts = 0
+			_, err := tfstate.ReadURL(t.Context(), ts.URL+tc.path, retry)
+			var statusErr *tfstate.HTTPStatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if statusErr.StatusCode != tc.status {
+				t.Errorf("unexpected status %d", statusErr.StatusCode)
+			}
+			if tc.is != nil && !errors.Is(err, tc.is) {
+				t.Errorf("error %v is not %v", err, tc.is)
+			}
+			if attempts != tc.attempts {
+				t.Errorf("unexpected attempts %d", attempts)
+			}
+		})
+	}
+}
+
+func TestHTTPRetryWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		opt        tfstate.HTTPRetryOption
+		attempt    int
+		retryAfter string
+		expected   time.Duration
+	}{
+		{"backoff", tfstate.HTTPRetryOption{WaitMin: time.Second, WaitMax: 10 * time.Second}, 2, "", 4 * time.Second},
+		{"WaitMax", tfstate.HTTPRetryOption{WaitMin: time.Second, WaitMax: 10 * time.Second}, 5, "", 10 * time.Second},
+		{"default WaitMin", tfstate.HTTPRetryOption{WaitMax: 10 * time.Second}, 1, "", 2 * time.Second},
+		{"default WaitMax", tfstate.HTTPRetryOption{WaitMin: time.Second}, 10, "", 30 * time.Second},
+		{"large attempt", tfstate.HTTPRetryOption{WaitMin: time.Second, WaitMax: time.Minute}, 100, "", time.Minute},
+		{"Retry-After", tfstate.HTTPRetryOption{}, 0, "3", 3 * time.Second},
+		{"Retry-After up to default WaitMax", tfstate.HTTPRetryOption{}, 0, "36000", 30 * time.Second},
+		{"large Retry-After", tfstate.HTTPRetryOption{WaitMax: time.Minute}, 0, "9223372036854775807", time.Minute},
+		{"invalid Retry-After", tfstate.HTTPRetryOption{}, 0, "soon", time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if d := tfstate.HTTPRetryWait(tc.opt, tc.attempt, tc.retryAfter); d != tc.expected {
+				t.Errorf("unexpected wait %s, expected %s", d, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	content := `# comment
+machine example.com login user password pass
+macdef init
+machine macro.example.com login macro password macro
+
+machine other.example.com
+  login other
+  account acct
+  password other-pass
+default login default password default-pass
+machine after-default.example.com login after password after-pass
+`
+	if err := os.WriteFile(netrc, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host, login, password string
+	}{
+		{"example.com", "user", "pass"},
+		{"EXAMPLE.COM", "user", "pass"},
+		{"other.example.com", "other", "other-pass"},
+		{"macro.example.com", "default", "default-pass"},
+		{"after-default.example.com", "after", "after-pass"},
+		{"unknown.example.com", "default", "default-pass"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.host, func(t *testing.T) {
+			login, password, err := tfstate.NetrcCredentials(netrc, tc.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if login != tc.login || password != tc.password {
+				t.Errorf("unexpected credentials %s:%s, expected %s:%s", login, password, tc.login, tc.password)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		login, password, err := tfstate.NetrcCredentials(filepath.Join(t.TempDir(), "missing"), "example.com")
+		if err != nil || login != "" || password != "" {
+			t.Errorf("unexpected result %s:%s %v", login, password, err)
+		}
+	})
+}
+
+func TestReadHTTPAuth(t *testing.T) {
+	var expected string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != expected {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Custom") != "custom-value" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	content := "machine example.com login other password other\n" +
+		"machine 127.0.0.1\n  login netrc-user\n  password netrc-pass\n" +
+		"default login default password default\n"
+	if err := os.WriteFile(netrc, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+	tests := []struct {
+		name     string
+		opts     []tfstate.ReadURLOption
+		expected string
+	}{
+		{"bearer", []tfstate.ReadURLOption{tfstate.HTTPBearerTokenOption("t0ken")}, "Bearer t0ken"},
+		{"basic", []tfstate.ReadURLOption{tfstate.HTTPBasicAuthOption{Username: "user", Password: "pass"}}, basic("user", "pass")},
+		{"netrc", []tfstate.ReadURLOption{tfstate.HTTPNetrcOption(true)}, basic("netrc-user", "netrc-pass")},
+		{"netrc with bearer", []tfstate.ReadURLOption{tfstate.HTTPNetrcOption(true), tfstate.HTTPBearerTokenOption("t0ken")}, "Bearer t0ken"},
+		{"header", []tfstate.ReadURLOption{tfstate.HTTPHeaderOption{Name: "Authorization", Value: "Token xyz"}}, "Token xyz"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expected = tc.expected
+			opts := append(tc.opts, tfstate.HTTPHeaderOption{Name: "X-Custom", Value: "custom-value"})
+			state, err := tfstate.ReadURL(t.Context(), ts.URL+"/terraform.tfstate", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		})
+	}
+}
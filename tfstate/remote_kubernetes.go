@@ -0,0 +1,223 @@
+//go:build !no_kubernetes
+
+package tfstate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	defaultKubernetesNamespace = "default"
+	kubernetesTFStateKey       = "tfstate"
+)
+
+type kubernetesOption struct {
+	namespace             string
+	secretSuffix          string
+	configPaths           []string
+	configContext         string
+	configContextAuthInfo string
+	configContextCluster  string
+	inCluster             bool
+	host                  string
+	token                 string
+	insecure              bool
+	clusterCACertificate  string
+	clientCertificate     string
+	clientKey             string
+}
+
+func newKubernetesOption() *kubernetesOption {
+	opt := &kubernetesOption{
+		namespace:             os.Getenv("KUBE_NAMESPACE"),
+		configContext:         os.Getenv("KUBE_CTX"),
+		configContextAuthInfo: os.Getenv("KUBE_CTX_AUTH_INFO"),
+		configContextCluster:  os.Getenv("KUBE_CTX_CLUSTER"),
+		inCluster:             os.Getenv("KUBE_IN_CLUSTER_CONFIG") == "true",
+		host:                  os.Getenv("KUBE_HOST"),
+		token:                 os.Getenv("KUBE_TOKEN"),
+		insecure:              os.Getenv("KUBE_INSECURE") == "true",
+		clusterCACertificate:  os.Getenv("KUBE_CLUSTER_CA_CERT_DATA"),
+		clientCertificate:     os.Getenv("KUBE_CLIENT_CERT_DATA"),
+		clientKey:             os.Getenv("KUBE_CLIENT_KEY_DATA"),
+	}
+	if p := os.Getenv("KUBE_CONFIG_PATH"); p != "" {
+		opt.configPaths = []string{p}
+	} else if ps := os.Getenv("KUBE_CONFIG_PATHS"); ps != "" {
+		opt.configPaths = filepath.SplitList(ps)
+	}
+	if opt.namespace == "" {
+		opt.namespace = defaultKubernetesNamespace
+	}
+	return opt
+}
+
+func readKubernetesState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	opt := newKubernetesOption()
+	for k, p := range map[string]*string{
+		"namespace":                &opt.namespace,
+		"secret_suffix":            &opt.secretSuffix,
+		"config_context":           &opt.configContext,
+		"config_context_auth_info": &opt.configContextAuthInfo,
+		"config_context_cluster":   &opt.configContextCluster,
+		"host":                     &opt.host,
+		"token":                    &opt.token,
+		"cluster_ca_certificate":   &opt.clusterCACertificate,
+		"client_certificate":       &opt.clientCertificate,
+		"client_key":               &opt.clientKey,
+	} {
+		if v := *strpe(config[k]); v != "" {
+			*p = v
+		}
+	}
+	if v := *strpe(config["config_path"]); v != "" {
+		opt.configPaths = []string{v}
+	} else if vs, ok := config["config_paths"].([]any); ok && len(vs) > 0 {
+		opt.configPaths = opt.configPaths[:0]
+		for _, v := range vs {
+			opt.configPaths = append(opt.configPaths, *strpe(v))
+		}
+	}
+	if v, ok := config["in_cluster_config"].(bool); ok {
+		opt.inCluster = v
+	}
+	if v, ok := config["insecure"].(bool); ok {
+		opt.insecure = v
+	}
+	if opt.secretSuffix == "" {
+		return nil, fmt.Errorf("kubernetes backend requires secret_suffix")
+	}
+	return readKubernetes(ctx, ws, *opt)
+}
+
+func readKubernetes(ctx context.Context, ws string, opt kubernetesOption) (io.ReadCloser, error) {
+	if ws == "" {
+		ws = defaultWorkspace
+	}
+	cfg, err := kubernetesRESTConfig(opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	selector := labels.SelectorFromSet(labels.Set{
+		kubernetesTFStateKey:  "true",
+		"tfstateSecretSuffix": opt.secretSuffix,
+		"tfstateWorkspace":    ws,
+	})
+	secrets, err := client.CoreV1().Secrets(opt.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	if len(secrets.Items) == 0 {
+		return nil, fmt.Errorf("secret tfstate-%s-%s is not found in namespace %s", ws, opt.secretSuffix, opt.namespace)
+	}
+
+	// Large states are split into tfstate-{ws}-{suffix}-part-{n} secrets.
+	// The API returns them sorted by name, so sort by the part index.
+	// The workspace may contain "-part-", so the index is taken after the name of the first secret.
+	items := secrets.Items
+	partPrefix := "tfstate-" + ws + "-" + opt.secretSuffix + "-part-"
+	partIndex := func(name string) int {
+		if part, ok := strings.CutPrefix(name, partPrefix); ok {
+			if i, err := strconv.Atoi(part); err == nil {
+				return i
+			}
+		}
+		return 0
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return partIndex(items[i].Name) < partIndex(items[j].Name)
+	})
+	var compressed []byte
+	for _, item := range items {
+		data, ok := item.Data[kubernetesTFStateKey]
+		if !ok {
+			return nil, fmt.Errorf("secret %s has no %s data", item.Name, kubernetesTFStateKey)
+		}
+		compressed = append(compressed, data...)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress state: %w", err)
+	}
+	return r, nil
+}
+
+func kubernetesRESTConfig(opt kubernetesOption) (*rest.Config, error) {
+	var cfg *rest.Config
+	if opt.inCluster {
+		c, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+		}
+		cfg = c
+	} else {
+		loader := clientcmd.NewDefaultClientConfigLoadingRules()
+		switch len(opt.configPaths) {
+		case 0:
+		case 1:
+			loader.ExplicitPath = opt.configPaths[0]
+		default:
+			loader.Precedence = opt.configPaths
+		}
+		overrides := &clientcmd.ConfigOverrides{
+			CurrentContext: opt.configContext,
+		}
+		overrides.Context.AuthInfo = opt.configContextAuthInfo
+		overrides.Context.Cluster = opt.configContextCluster
+		c, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides).ClientConfig()
+		switch {
+		case err == nil:
+		case opt.host != "":
+			// configured entirely by the backend config
+			c = &rest.Config{}
+		case clientcmd.IsEmptyConfig(err):
+			if c, err = rest.InClusterConfig(); err != nil {
+				return nil, fmt.Errorf("no kubeconfig found and failed to load in-cluster config: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		cfg = c
+	}
+
+	if opt.host != "" {
+		cfg.Host = opt.host
+	}
+	if opt.token != "" {
+		cfg.BearerToken = opt.token
+	}
+	if opt.insecure {
+		cfg.Insecure = true
+	}
+	if opt.clusterCACertificate != "" {
+		cfg.CAData = []byte(opt.clusterCACertificate)
+	}
+	if opt.clientCertificate != "" {
+		cfg.CertData = []byte(opt.clientCertificate)
+	}
+	if opt.clientKey != "" {
+		cfg.KeyData = []byte(opt.clientKey)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,13 @@
+//go:build no_kubernetes
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+func readKubernetesState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("kubernetes backend is not available (built with no_kubernetes tag)")
+}
@@ -0,0 +1,131 @@
+//go:build !no_kubernetes
+
+package tfstate_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func newKubernetesServer(t *testing.T, secrets map[string][]map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/tfstate/secrets" {
+			http.NotFound(w, r)
+			return
+		}
+		var items []map[string]any
+		for ws, ss := range secrets {
+			if strings.Contains(r.URL.Query().Get("labelSelector"), "tfstateWorkspace="+ws) {
+				items = ss
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"kind":       "SecretList",
+			"apiVersion": "v1",
+			"items":      items,
+		})
+	}))
+}
+
+func kubernetesSecret(name string, data []byte) map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{"name": name},
+		"data":     map[string]any{"tfstate": data}, // []byte is encoded as base64
+	}
+}
+
+func TestReadKubernetes(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(b)
+	zw.Close()
+	compressed := gz.Bytes()
+	half := len(compressed) / 2
+	third := len(compressed) / 3
+
+	ts := newKubernetesServer(t, map[string][]map[string]any{
+		"default": {kubernetesSecret("tfstate-default-app", compressed)},
+		"staging": {
+			// returned in name order, not in part order
+			kubernetesSecret("tfstate-staging-app-part-1", compressed[half:]),
+			kubernetesSecret("tfstate-staging-app", compressed[:half]),
+		},
+		"feature-part-2": {
+			// the workspace name contains "-part-"
+			kubernetesSecret("tfstate-feature-part-2-app-part-2", compressed[2*third:]),
+			kubernetesSecret("tfstate-feature-part-2-app-part-1", compressed[third:2*third]),
+			kubernetesSecret("tfstate-feature-part-2-app", compressed[:third]),
+		},
+	})
+	defer ts.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, fmt.Appendf(nil, `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+users:
+- name: test
+  user:
+    token: test-token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`, ts.URL), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	backend := fmt.Sprintf(`{
+  "version": 3,
+  "backend": {
+    "type": "kubernetes",
+    "config": {"namespace": "tfstate", "secret_suffix": "app", "config_path": %q}
+  }
+}`, kubeconfig)
+
+	for _, ws := range []string{"default", "staging", "feature-part-2"} {
+		t.Run("backend "+ws, func(t *testing.T) {
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(backend), ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		})
+	}
+
+	t.Run("URL", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), "kubernetes://tfstate/app?workspace=staging")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := tfstate.ReadURL(t.Context(), "kubernetes://tfstate/app?workspace=missing"); err == nil {
+			t.Error("expected error for a missing workspace")
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package tfstate
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultLocalStatePath    = "terraform.tfstate"
+	defaultLocalWorkspaceDir = "terraform.tfstate.d"
+)
+
+// readLocalState reads a state of the local backend.
+// The relative path and workspace_dir are resolved from the working directory of terraform.
+func readLocalState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	dir := workDirFrom(ctx)
+	statePath, workspaceDir := defaultLocalStatePath, defaultLocalWorkspaceDir
+	if p := *strpe(config["path"]); p != "" {
+		statePath = p
+	}
+	if d := *strpe(config["workspace_dir"]); d != "" {
+		workspaceDir = d
+	}
+	if ws != defaultWorkspace {
+		statePath = filepath.Join(workspaceDir, ws, defaultLocalStatePath)
+	}
+	if !filepath.IsAbs(statePath) {
+		statePath = filepath.Join(dir, statePath)
+	}
+	return os.Open(statePath)
+}
@@ -0,0 +1,131 @@
+package tfstate_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func localTestState(value string) string {
+	return fmt.Sprintf(`{"version": 4, "outputs": {"ws": {"value": %q, "type": "string"}}}`, value)
+}
+
+func lookupWorkspaceOutput(t *testing.T, file string) string {
+	t.Helper()
+	state, err := tfstate.ReadFile(t.Context(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stateWorkspaceOutput(t, state)
+}
+
+// stateWorkspaceOutput returns output.ws of the state written by localTestState.
+func stateWorkspaceOutput(t *testing.T, state *tfstate.TFState) string {
+	t.Helper()
+	obj, err := state.Lookup("output.ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj.String()
+}
+
+func TestReadFileLocalWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "terraform.tfstate"), localTestState("default"))
+	writeTestFile(t, filepath.Join(dir, "terraform.tfstate.d", "staging", "terraform.tfstate"), localTestState("staging"))
+	writeTestFile(t, filepath.Join(dir, ".terraform", "terraform.tfstate"), `{
+  "version": 3,
+  "backend": {
+    "type": "local",
+    "config": {"path": null, "workspace_dir": null}
+  }
+}`)
+	backendFile := filepath.Join(dir, ".terraform", "terraform.tfstate")
+
+	t.Run("default", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		if v := lookupWorkspaceOutput(t, backendFile); v != "default" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("TF_WORKSPACE", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "staging")
+		if v := lookupWorkspaceOutput(t, backendFile); v != "staging" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("environment file", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		writeTestFile(t, filepath.Join(dir, ".terraform", "environment"), "staging")
+		defer os.Remove(filepath.Join(dir, ".terraform", "environment"))
+		if v := lookupWorkspaceOutput(t, backendFile); v != "staging" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("missing workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "production")
+		if _, err := tfstate.ReadFile(t.Context(), backendFile); err == nil {
+			t.Error("expected error for a missing workspace")
+		}
+	})
+
+	t.Run("state file in the working directory", func(t *testing.T) {
+		stateFile := filepath.Join(dir, "terraform.tfstate")
+		for ws, expected := range map[string]string{"": "default", "default": "default", "staging": "staging"} {
+			t.Setenv("TF_WORKSPACE", ws)
+			if v := lookupWorkspaceOutput(t, stateFile); v != expected {
+				t.Errorf("unexpected value %s for workspace %q", v, ws)
+			}
+		}
+		t.Setenv("TF_WORKSPACE", "production")
+		if _, err := tfstate.ReadFile(t.Context(), stateFile); err == nil {
+			t.Error("expected error for a missing workspace")
+		}
+	})
+}
+
+func TestReadLocalBackend(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "states", "app.tfstate"), localTestState("default"))
+	writeTestFile(t, filepath.Join(dir, "workspaces", "staging", "terraform.tfstate"), localTestState("staging"))
+	writeTestFile(t, filepath.Join(dir, ".terraform", "terraform.tfstate"), `{
+  "version": 3,
+  "backend": {
+    "type": "local",
+    "config": {"path": "states/app.tfstate", "workspace_dir": "workspaces"}
+  }
+}`)
+	file := filepath.Join(dir, ".terraform", "terraform.tfstate")
+
+	t.Run("default", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		if v := lookupWorkspaceOutput(t, file); v != "default" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("environment file", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		writeTestFile(t, filepath.Join(dir, ".terraform", "environment"), "staging\n")
+		defer os.Remove(filepath.Join(dir, ".terraform", "environment"))
+		if v := lookupWorkspaceOutput(t, file); v != "staging" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+}
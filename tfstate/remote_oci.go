@@ -0,0 +1,163 @@
+//go:build !no_oci
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+const (
+	defaultOCIKey                = "terraform.tfstate"
+	defaultOCIWorkspaceKeyPrefix = "tf-state-env"
+
+	// OCIEndpointEnvKey is the environment variable of the Object Storage endpoint to access instead of the regional one.
+	OCIEndpointEnvKey = "OCI_OBJECT_STORAGE_ENDPOINT"
+)
+
+type ociOption struct {
+	auth               string
+	region             string
+	configFileProfile  string
+	tenancyOCID        string
+	userOCID           string
+	fingerprint        string
+	privateKey         string
+	privateKeyPath     string
+	privateKeyPassword string
+	endpoint           string
+}
+
+func newOCIOption() *ociOption {
+	return &ociOption{
+		auth:              os.Getenv("OCI_AUTH"),
+		region:            os.Getenv("OCI_REGION"),
+		configFileProfile: os.Getenv("OCI_CLI_PROFILE"),
+		endpoint:          os.Getenv(OCIEndpointEnvKey),
+	}
+}
+
+func readOCIState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	namespace, bucket := *strpe(config["namespace"]), *strpe(config["bucket"])
+	if namespace == "" {
+		return nil, fmt.Errorf("oci backend requires namespace")
+	}
+	key := defaultOCIKey
+	if k := *strpe(config["key"]); k != "" {
+		key = k
+	}
+	if ws != defaultWorkspace {
+		if prefix := strp(config["workspace_key_prefix"]); prefix != nil {
+			key = path.Join(*prefix, ws, key)
+		} else {
+			key = path.Join(defaultOCIWorkspaceKeyPrefix, ws, key)
+		}
+	}
+	opt := newOCIOption()
+	if cfg := readURLConfigFrom(ctx); cfg != nil && cfg.ociEndpoint != "" {
+		opt.endpoint = cfg.ociEndpoint
+	}
+	for k, p := range map[string]*string{
+		"auth":                 &opt.auth,
+		"region":               &opt.region,
+		"config_file_profile":  &opt.configFileProfile,
+		"tenancy_ocid":         &opt.tenancyOCID,
+		"user_ocid":            &opt.userOCID,
+		"fingerprint":          &opt.fingerprint,
+		"private_key":          &opt.privateKey,
+		"private_key_path":     &opt.privateKeyPath,
+		"private_key_password": &opt.privateKeyPassword,
+	} {
+		if v := *strpe(config[k]); v != "" {
+			*p = v
+		}
+	}
+	return readOCI(ctx, namespace, bucket, key, *opt)
+}
+
+func readOCI(ctx context.Context, namespace, bucket, key string, opt ociOption) (io.ReadCloser, error) {
+	provider, err := newOCIConfigurationProvider(opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := objectstorage.NewObjectStorageClientWithConfigurationProvider(provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object storage client: %w", err)
+	}
+	if opt.region != "" {
+		client.SetRegion(opt.region)
+	}
+	if opt.endpoint != "" {
+		client.Host = opt.endpoint
+	}
+	res, err := client.GetObject(ctx, objectstorage.GetObjectRequest{
+		NamespaceName: common.String(namespace),
+		BucketName:    common.String(bucket),
+		ObjectName:    common.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Content, nil
+}
+
+func newOCIConfigurationProvider(opt ociOption) (common.ConfigurationProvider, error) {
+	switch strings.ToLower(opt.auth) {
+	case "", "apikey":
+	case "instanceprincipal":
+		if opt.region != "" {
+			return auth.InstancePrincipalConfigurationProviderForRegion(common.StringToRegion(opt.region))
+		}
+		return auth.InstancePrincipalConfigurationProvider()
+	case "resourceprincipal":
+		return auth.ResourcePrincipalConfigurationProvider()
+	case "okeworkloadidentity":
+		return auth.OkeWorkloadIdentityConfigurationProvider()
+	case "securitytoken":
+		profile := opt.configFileProfile
+		if profile == "" {
+			profile = "DEFAULT"
+		}
+		return common.ConfigurationProviderForSessionTokenWithProfile(ociConfigFilePath(), profile, opt.privateKeyPassword)
+	default:
+		return nil, fmt.Errorf("unsupported oci auth type: %s", opt.auth)
+	}
+
+	// API key authentication
+	if opt.tenancyOCID != "" && opt.userOCID != "" && opt.fingerprint != "" {
+		privateKey := opt.privateKey
+		if privateKey == "" && opt.privateKeyPath != "" {
+			b, err := os.ReadFile(opt.privateKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key: %w", err)
+			}
+			privateKey = string(b)
+		}
+		var password *string
+		if opt.privateKeyPassword != "" {
+			password = common.String(opt.privateKeyPassword)
+		}
+		return common.NewRawConfigurationProvider(opt.tenancyOCID, opt.userOCID, opt.region, opt.fingerprint, privateKey, password), nil
+	}
+	if opt.configFileProfile != "" {
+		return common.CustomProfileConfigProvider(ociConfigFilePath(), opt.configFileProfile), nil
+	}
+	return common.DefaultConfigProvider(), nil
+}
+
+func ociConfigFilePath() string {
+	if p := os.Getenv("OCI_CLI_CONFIG_FILE"); p != "" {
+		return p
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".oci", "config")
+}
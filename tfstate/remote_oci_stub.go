@@ -0,0 +1,15 @@
+//go:build no_oci
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+const OCIEndpointEnvKey = "OCI_OBJECT_STORAGE_ENDPOINT"
+
+func readOCIState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("OCI backend is not available (built with no_oci tag)")
+}
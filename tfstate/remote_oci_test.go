@@ -0,0 +1,182 @@
+//go:build !no_oci
+
+package tfstate_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+const (
+	testOCITenancy     = "ocid1.tenancy.oc1..test"
+	testOCIUser        = "ocid1.user.oc1..test"
+	testOCIFingerprint = "11:22:33:44"
+)
+
+// newOCIServer returns a fake Object Storage server that serves objects of the namespace and the bucket.
+func newOCIServer(t *testing.T, namespace, bucket string, objects map[string]string) *httptest.Server {
+	t.Helper()
+	keyID := fmt.Sprintf(`keyId="%s/%s/%s"`, testOCITenancy, testOCIUser, testOCIFingerprint)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), keyID) {
+			http.Error(w, "NotAuthenticated", http.StatusUnauthorized)
+			return
+		}
+		prefix := fmt.Sprintf("/n/%s/b/%s/o/", namespace, bucket)
+		name, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		v, ok := objects[name]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"code":    "ObjectNotFound",
+				"message": fmt.Sprintf("The object '%s' was not found in the bucket '%s'", name, bucket),
+			})
+			return
+		}
+		fmt.Fprint(w, v)
+	}))
+}
+
+func testOCIPrivateKey(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestReadOCI(t *testing.T) {
+	ts := newOCIServer(t, "myns", "mybucket", map[string]string{
+		"terraform.tfstate":                      localTestState("default"),
+		"tf-state-env/staging/terraform.tfstate": localTestState("staging"),
+		"envs/staging/app.tfstate":               localTestState("app-staging"),
+		"path/to/terraform.tfstate":              localTestState("url"),
+	})
+	defer ts.Close()
+	t.Setenv(tfstate.OCIEndpointEnvKey, ts.URL)
+
+	privateKey := testOCIPrivateKey(t)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	writeTestFile(t, keyFile, privateKey)
+	configFile := filepath.Join(t.TempDir(), "config")
+	writeTestFile(t, configFile, fmt.Sprintf(`[TEST]
+user=%s
+fingerprint=%s
+tenancy=%s
+region=us-ashburn-1
+key_file=%s
+`, testOCIUser, testOCIFingerprint, testOCITenancy, keyFile))
+	t.Setenv("OCI_CLI_CONFIG_FILE", configFile)
+	t.Setenv("OCI_AUTH", "")
+	t.Setenv("OCI_REGION", "")
+	t.Setenv("OCI_CLI_PROFILE", "")
+
+	credentials := map[string]any{
+		"tenancy_ocid": testOCITenancy,
+		"user_ocid":    testOCIUser,
+		"fingerprint":  testOCIFingerprint,
+		"private_key":  privateKey,
+		"region":       "us-ashburn-1",
+	}
+	backend := func(t *testing.T, config map[string]any) string {
+		t.Helper()
+		for k, v := range credentials {
+			config[k] = v
+		}
+		b, err := json.Marshal(map[string]any{
+			"version": 3,
+			"backend": map[string]any{"type": "oci", "config": config},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]any
+		ws     string
+		want   string
+	}{
+		{"default", map[string]any{"namespace": "myns", "bucket": "mybucket"}, "default", "default"},
+		{"default workspace key prefix", map[string]any{"namespace": "myns", "bucket": "mybucket"}, "staging", "staging"},
+		{"custom workspace key prefix and key", map[string]any{"namespace": "myns", "bucket": "mybucket", "workspace_key_prefix": "envs", "key": "app.tfstate"}, "staging", "app-staging"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(backend(t, tc.config)), tc.ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := stateWorkspaceOutput(t, state); v != tc.want {
+				t.Errorf("unexpected value %s", v)
+			}
+		})
+	}
+
+	t.Run("namespace is required", func(t *testing.T) {
+		src := backend(t, map[string]any{"bucket": "mybucket"})
+		if _, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(src), "default"); err == nil || !strings.Contains(err.Error(), "namespace") {
+			t.Errorf("expected error for a missing namespace, got %v", err)
+		}
+		if _, err := tfstate.ReadURL(t.Context(), "oci:///mybucket/terraform.tfstate?profile=TEST"); err == nil || !strings.Contains(err.Error(), "namespace") {
+			t.Errorf("expected error for a missing namespace, got %v", err)
+		}
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), "oci://myns/mybucket/path/to/terraform.tfstate?profile=TEST&region=us-phoenix-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "url" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("URL with OCIEndpointOption", func(t *testing.T) {
+		t.Setenv(tfstate.OCIEndpointEnvKey, "http://127.0.0.1:1")
+		state, err := tfstate.ReadURL(t.Context(), "oci://myns/mybucket/path/to/terraform.tfstate?profile=TEST", tfstate.OCIEndpointOption(ts.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "url" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		for _, u := range []string{
+			"oci://myns/mybucket",
+			"oci://myns/mybucket/terraform.tfstate?auth=unknown",
+		} {
+			if _, err := tfstate.ReadURL(t.Context(), u); err == nil {
+				t.Errorf("expected error for %s", u)
+			}
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := tfstate.ReadURL(t.Context(), "oci://myns/mybucket/missing.tfstate?profile=TEST"); err == nil {
+			t.Error("expected error for a missing object")
+		}
+	})
+}
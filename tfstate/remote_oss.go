@@ -0,0 +1,211 @@
+//go:build !no_oss
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
+	"github.com/aliyun/credentials-go/credentials/providers"
+)
+
+const (
+	OSSEndpointEnvKey        = "ALICLOUD_OSS_ENDPOINT"
+	defaultOSSPrefix         = "env:"
+	defaultOSSKey            = "terraform.tfstate"
+	defaultOSSRoleSession    = "terraform"
+	defaultOSSRoleExpiration = 3600
+)
+
+// OSSOption represents options for reading a state from Alibaba Cloud OSS.
+type OSSOption struct {
+	AccessKey             string
+	SecretKey             string
+	SecurityToken         string
+	Region                string
+	Endpoint              string
+	ECSRoleName           string
+	RoleArn               string
+	RoleSessionName       string
+	RolePolicy            string
+	RoleSessionExpiration int
+	STSEndpoint           string
+}
+
+func newOSSOption() *OSSOption {
+	opt := &OSSOption{
+		AccessKey:       os.Getenv("ALICLOUD_ACCESS_KEY"),
+		SecretKey:       os.Getenv("ALICLOUD_SECRET_KEY"),
+		SecurityToken:   os.Getenv("ALICLOUD_SECURITY_TOKEN"),
+		Region:          os.Getenv("ALICLOUD_REGION"),
+		Endpoint:        os.Getenv(OSSEndpointEnvKey),
+		ECSRoleName:     os.Getenv("ALICLOUD_ECS_ROLE_NAME"),
+		RoleArn:         os.Getenv("ALICLOUD_ASSUME_ROLE_ARN"),
+		RoleSessionName: os.Getenv("ALICLOUD_ASSUME_ROLE_SESSION_NAME"),
+		STSEndpoint:     os.Getenv("ALICLOUD_STS_ENDPOINT"),
+	}
+	if opt.AccessKey == "" {
+		opt.AccessKey = os.Getenv("ALICLOUD_ACCESS_KEY_ID")
+	}
+	if opt.SecretKey == "" {
+		opt.SecretKey = os.Getenv("ALICLOUD_ACCESS_KEY_SECRET")
+	}
+	if opt.Region == "" {
+		opt.Region = os.Getenv("ALICLOUD_DEFAULT_REGION")
+	}
+	if opt.Endpoint == "" {
+		opt.Endpoint = os.Getenv("OSS_ENDPOINT")
+	}
+	if v, err := strconv.Atoi(os.Getenv("ALICLOUD_ASSUME_ROLE_SESSION_EXPIRATION")); err == nil {
+		opt.RoleSessionExpiration = v
+	}
+	return opt
+}
+
+func readOSSState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	bucket := *strpe(config["bucket"])
+	prefix, key := defaultOSSPrefix, defaultOSSKey
+	if p := strp(config["prefix"]); p != nil {
+		prefix = *p
+	}
+	if k := *strpe(config["key"]); k != "" {
+		key = k
+	}
+	if ws != defaultWorkspace {
+		key = path.Join(prefix, ws, key)
+	} else {
+		key = path.Join(prefix, key)
+	}
+	return readOSS(ctx, bucket, key, *newOSSStateOption(ctx, config))
+}
+
+// newOSSStateOption returns the option to read the state by the backend config.
+func newOSSStateOption(ctx context.Context, config map[string]any) *OSSOption {
+	opt := newOSSOption()
+	if cfg := readURLConfigFrom(ctx); cfg != nil && cfg.ossEndpoint != "" {
+		opt.Endpoint = cfg.ossEndpoint
+	}
+	for k, p := range map[string]*string{
+		"access_key":     &opt.AccessKey,
+		"secret_key":     &opt.SecretKey,
+		"security_token": &opt.SecurityToken,
+		"region":         &opt.Region,
+		"endpoint":       &opt.Endpoint,
+		"ecs_role_name":  &opt.ECSRoleName,
+		"sts_endpoint":   &opt.STSEndpoint,
+	} {
+		if v := *strpe(config[k]); v != "" {
+			*p = v
+		}
+	}
+	if v := *strpe(config["assume_role_role_arn"]); v != "" {
+		opt.RoleArn = v
+		opt.RoleSessionName = *strpe(config["assume_role_session_name"])
+		opt.RolePolicy = *strpe(config["assume_role_policy"])
+		if v, ok := config["assume_role_session_expiration"].(float64); ok {
+			opt.RoleSessionExpiration = int(v)
+		}
+	} else if ar := configBlock(config["assume_role"]); ar != nil {
+		// deprecated assume_role block
+		opt.RoleArn = *strpe(ar["role_arn"])
+		opt.RoleSessionName = *strpe(ar["session_name"])
+		opt.RolePolicy = *strpe(ar["policy"])
+		if v, ok := ar["session_expiration"].(float64); ok {
+			opt.RoleSessionExpiration = int(v)
+		}
+	}
+	return opt
+}
+
+func readOSS(ctx context.Context, bucket, key string, opt OSSOption) (io.ReadCloser, error) {
+	provider, err := newOSSCredentialsProvider(opt)
+	if err != nil {
+		return nil, err
+	}
+	cfg := oss.LoadDefaultConfig().
+		WithCredentialsProvider(provider).
+		WithRegion(opt.Region)
+	if opt.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opt.Endpoint)
+	}
+	client := oss.NewClient(cfg)
+	result, err := client.GetObject(ctx, &oss.GetObjectRequest{
+		Bucket: oss.Ptr(bucket),
+		Key:    oss.Ptr(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+func newOSSCredentialsProvider(opt OSSOption) (credentials.CredentialsProvider, error) {
+	var base providers.CredentialsProvider
+	var err error
+	switch {
+	case opt.AccessKey != "" && opt.SecretKey != "" && opt.SecurityToken != "":
+		base, err = providers.NewStaticSTSCredentialsProviderBuilder().
+			WithAccessKeyId(opt.AccessKey).
+			WithAccessKeySecret(opt.SecretKey).
+			WithSecurityToken(opt.SecurityToken).
+			Build()
+	case opt.AccessKey != "" && opt.SecretKey != "":
+		base, err = providers.NewStaticAKCredentialsProviderBuilder().
+			WithAccessKeyId(opt.AccessKey).
+			WithAccessKeySecret(opt.SecretKey).
+			Build()
+	case opt.ECSRoleName != "":
+		base, err = providers.NewECSRAMRoleCredentialsProviderBuilder().
+			WithRoleName(opt.ECSRoleName).
+			Build()
+	default:
+		base = providers.NewDefaultCredentialsProvider()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credentials provider: %w", err)
+	}
+
+	if opt.RoleArn != "" {
+		sessionName, expiration := opt.RoleSessionName, opt.RoleSessionExpiration
+		if sessionName == "" {
+			sessionName = defaultOSSRoleSession
+		}
+		if expiration == 0 {
+			expiration = defaultOSSRoleExpiration
+		}
+		b := providers.NewRAMRoleARNCredentialsProviderBuilder().
+			WithCredentialsProvider(base).
+			WithRoleArn(opt.RoleArn).
+			WithRoleSessionName(sessionName).
+			WithDurationSeconds(expiration)
+		if opt.RolePolicy != "" {
+			b = b.WithPolicy(opt.RolePolicy)
+		}
+		if opt.STSEndpoint != "" {
+			b = b.WithStsEndpoint(opt.STSEndpoint)
+		} else if opt.Region != "" {
+			b = b.WithStsRegionId(opt.Region)
+		}
+		if base, err = b.Build(); err != nil {
+			return nil, fmt.Errorf("failed to create assume role provider: %w", err)
+		}
+	}
+
+	return credentials.CredentialsProviderFunc(func(ctx context.Context) (credentials.Credentials, error) {
+		cc, err := base.GetCredentials()
+		if err != nil {
+			return credentials.Credentials{}, err
+		}
+		return credentials.Credentials{
+			AccessKeyID:     cc.AccessKeyId,
+			AccessKeySecret: cc.AccessKeySecret,
+			SecurityToken:   cc.SecurityToken,
+		}, nil
+	}), nil
+}
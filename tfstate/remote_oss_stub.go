@@ -0,0 +1,30 @@
+//go:build no_oss
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+const OSSEndpointEnvKey = "ALICLOUD_OSS_ENDPOINT"
+
+// OSSOption represents options for reading a state from Alibaba Cloud OSS.
+type OSSOption struct {
+	AccessKey             string
+	SecretKey             string
+	SecurityToken         string
+	Region                string
+	Endpoint              string
+	ECSRoleName           string
+	RoleArn               string
+	RoleSessionName       string
+	RolePolicy            string
+	RoleSessionExpiration int
+	STSEndpoint           string
+}
+
+func readOSSState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("OSS backend is not available (built with no_oss tag)")
+}
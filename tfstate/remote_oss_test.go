@@ -0,0 +1,145 @@
+//go:build !no_oss
+
+package tfstate_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// newOSSServer returns a fake OSS server that serves objects by path style requests.
+func newOSSServer(t *testing.T, bucket string, objects map[string][]byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "OSS4-HMAC-SHA256 Credential=testkey/") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		b, ok := objects[strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Write(b)
+	}))
+}
+
+func TestReadOSS(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newOSSServer(t, "mybucket", map[string][]byte{
+		"env:/terraform.tfstate":         b,
+		"states/staging/app.tfstate":     b,
+		"path/to/terraform.tfstate":      b,
+		"env:/staging/terraform.tfstate": b,
+	})
+	defer ts.Close()
+	t.Setenv("ALICLOUD_ACCESS_KEY", "testkey")
+	t.Setenv("ALICLOUD_SECRET_KEY", "testsecret")
+	t.Setenv("ALICLOUD_REGION", "cn-hangzhou")
+	t.Setenv(tfstate.OSSEndpointEnvKey, "")
+
+	tests := []struct {
+		name   string
+		config string
+		ws     string
+	}{
+		{"default", `{"bucket": "mybucket", "endpoint": "%s"}`, "default"},
+		{"default workspace prefix", `{"bucket": "mybucket", "endpoint": "%s"}`, "staging"},
+		{"custom prefix and key", `{"bucket": "mybucket", "prefix": "states", "key": "app.tfstate", "endpoint": "%s"}`, "staging"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src := fmt.Sprintf(`{"version": 3, "backend": {"type": "oss", "config": %s}}`, fmt.Sprintf(tc.config, ts.URL))
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(src), tc.ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		})
+	}
+
+	t.Run("URL with OSSEndpointOption", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), "oss://mybucket/path/to/terraform.tfstate", tfstate.OSSEndpointOption(ts.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("URL with OSS_ENDPOINT", func(t *testing.T) {
+		t.Setenv("OSS_ENDPOINT", ts.URL)
+		state, err := tfstate.ReadURL(t.Context(), "oss://mybucket/path/to/terraform.tfstate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("URL with ALICLOUD_OSS_ENDPOINT", func(t *testing.T) {
+		t.Setenv(tfstate.OSSEndpointEnvKey, ts.URL)
+		state, err := tfstate.ReadURL(t.Context(), "oss://mybucket/path/to/terraform.tfstate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := tfstate.ReadURL(t.Context(), "oss://mybucket/missing.tfstate", tfstate.OSSEndpointOption(ts.URL)); err == nil {
+			t.Error("expected error for a missing object")
+		}
+	})
+}
+
+func TestOSSStateOptionAssumeRole(t *testing.T) {
+	t.Setenv("ALICLOUD_ASSUME_ROLE_ARN", "")
+	t.Setenv("ALICLOUD_ASSUME_ROLE_SESSION_NAME", "")
+	t.Setenv("ALICLOUD_ASSUME_ROLE_SESSION_EXPIRATION", "")
+	ar := map[string]any{
+		"role_arn":           "acs:ram::123456789012:role/block",
+		"session_name":       "block-session",
+		"policy":             `{"Version":"1"}`,
+		"session_expiration": float64(900),
+	}
+	expected := tfstate.OSSOption{
+		RoleArn:               "acs:ram::123456789012:role/block",
+		RoleSessionName:       "block-session",
+		RolePolicy:            `{"Version":"1"}`,
+		RoleSessionExpiration: 900,
+	}
+	tests := []struct {
+		name     string
+		config   map[string]any
+		expected tfstate.OSSOption
+	}{
+		{"deprecated block as object", map[string]any{"assume_role": ar}, expected},
+		{"deprecated block as list", map[string]any{"assume_role": []any{ar}}, expected},
+		{"flat attributes take precedence", map[string]any{
+			"assume_role":                    ar,
+			"assume_role_role_arn":           "acs:ram::123456789012:role/flat",
+			"assume_role_session_expiration": float64(1200),
+		}, tfstate.OSSOption{RoleArn: "acs:ram::123456789012:role/flat", RoleSessionExpiration: 1200}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opt := tfstate.NewOSSStateOption(tc.config)
+			if opt.RoleArn != tc.expected.RoleArn ||
+				opt.RoleSessionName != tc.expected.RoleSessionName ||
+				opt.RolePolicy != tc.expected.RolePolicy ||
+				opt.RoleSessionExpiration != tc.expected.RoleSessionExpiration {
+				t.Errorf("unexpected assume role %#v, expected %#v", opt, tc.expected)
+			}
+		})
+	}
+}
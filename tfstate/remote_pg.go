@@ -0,0 +1,53 @@
+//go:build !no_pg
+
+package tfstate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const defaultPgSchemaName = "terraform_remote_state"
+
+func readPgState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	connStr := *strpe(config["conn_str"])
+	if connStr == "" {
+		connStr = os.Getenv("PG_CONN_STR")
+	}
+	schemaName := *strpe(config["schema_name"])
+	if schemaName == "" {
+		schemaName = os.Getenv("PG_SCHEMA_NAME")
+	}
+	return readPg(ctx, connStr, schemaName, ws)
+}
+
+func readPg(ctx context.Context, connStr, schemaName, ws string) (io.ReadCloser, error) {
+	if schemaName == "" {
+		schemaName = defaultPgSchemaName
+	}
+	if ws == "" {
+		ws = defaultWorkspace
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var data string
+	query := fmt.Sprintf(`SELECT data FROM %s.states WHERE name = $1`, pq.QuoteIdentifier(schemaName))
+	if err := db.QueryRowContext(ctx, query, ws).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("workspace %s is not found in %s.states", ws, schemaName)
+		}
+		return nil, fmt.Errorf("failed to select state: %w", err)
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
@@ -0,0 +1,13 @@
+//go:build no_pg
+
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+func readPgState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("pg backend is not available (built with no_pg tag)")
+}
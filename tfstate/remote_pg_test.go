@@ -0,0 +1,85 @@
+//go:build !no_pg
+
+package tfstate_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	_ "github.com/lib/pq"
+)
+
+func TestReadPg(t *testing.T) {
+	connStr := os.Getenv("PG_CONN_STR")
+	if connStr == "" {
+		t.Skip("PG_CONN_STR is not set")
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, q := range []string{
+		`CREATE SCHEMA IF NOT EXISTS tfstate_lookup_test`,
+		`CREATE TABLE IF NOT EXISTS tfstate_lookup_test.states (id bigserial primary key, name text, data text)`,
+		`DELETE FROM tfstate_lookup_test.states`,
+	} {
+		if _, err := db.ExecContext(t.Context(), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ws := range []string{"default", "staging"} {
+		if _, err := db.ExecContext(t.Context(), `INSERT INTO tfstate_lookup_test.states (name, data) VALUES ($1, $2)`, ws, localTestState(ws)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend := `{
+  "version": 3,
+  "backend": {
+    "type": "pg",
+    "config": {"schema_name": "tfstate_lookup_test"}
+  }
+}`
+	for _, ws := range []string{"default", "staging"} {
+		t.Run("backend "+ws, func(t *testing.T) {
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(backend), ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := stateWorkspaceOutput(t, state); v != ws {
+				t.Errorf("unexpected value %s", v)
+			}
+		})
+	}
+
+	t.Run("missing workspace", func(t *testing.T) {
+		if _, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(backend), "missing"); err == nil {
+			t.Error("expected error for a missing workspace")
+		}
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		u, err := url.Parse(connStr)
+		if err != nil || u.Scheme == "" {
+			t.Skipf("PG_CONN_STR is not a URL: %s", connStr)
+		}
+		u.Scheme = "pg"
+		q := u.Query()
+		q.Set("schema_name", "tfstate_lookup_test")
+		q.Set("workspace", "staging")
+		u.RawQuery = q.Encode()
+		state, err := tfstate.ReadURL(t.Context(), fmt.Sprint(u))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := stateWorkspaceOutput(t, state); v != "staging" {
+			t.Errorf("unexpected value %s", v)
+		}
+	})
+}
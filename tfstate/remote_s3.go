@@ -3,11 +3,16 @@
 package tfstate
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"sort"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
@@ -16,27 +21,99 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
 	"github.com/aws/smithy-go/middleware"
 	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
-const S3EndpointEnvKey = "AWS_ENDPOINT_URL_S3"
+const (
+	S3EndpointEnvKey       = "AWS_ENDPOINT_URL_S3"
+	S3SSECustomerKeyEnvKey = "AWS_SSE_CUSTOMER_KEY"
+)
 
 type S3Option struct {
 	AccessKey string
 	SecretKey string
+	Token     string
 	Region    string
-	RoleArn   string
+	RoleArn   string // deprecated: use AssumeRole.RoleArn
 	Endpoint  string
+
+	STSEndpoint          string
+	UsePathStyle         bool
+	UseFIPSEndpoint      bool
+	UseDualStackEndpoint bool
+	CustomCABundle       string
+
+	// SkipRegionValidation trusts Region as the bucket region
+	// and skips the bucket region detection.
+	SkipRegionValidation bool
+
+	// SSECustomerKey is a base64 encoded 256-bit key for SSE-C encrypted states.
+	SSECustomerKey string
+
+	// VersionID selects a version of the state object.
+	// AsOf selects the newest version at or before the time when VersionID is empty.
+	VersionID string
+	AsOf      time.Time
+
+	Profile                string
+	SharedConfigFiles      []string
+	SharedCredentialsFiles []string
+
+	AssumeRole                *S3AssumeRole
+	AssumeRoleWithWebIdentity *S3AssumeRoleWithWebIdentity
+}
+
+// S3AssumeRole represents the assume_role block of the S3 backend.
+type S3AssumeRole struct {
+	RoleArn           string
+	ExternalID        string
+	SessionName       string
+	Duration          time.Duration
+	Policy            string
+	PolicyArns        []string
+	SourceIdentity    string
+	Tags              map[string]string
+	TransitiveTagKeys []string
+}
+
+// S3AssumeRoleWithWebIdentity represents the assume_role_with_web_identity block of the S3 backend.
+type S3AssumeRoleWithWebIdentity struct {
+	RoleArn              string
+	SessionName          string
+	WebIdentityToken     string
+	WebIdentityTokenFile string
+	Duration             time.Duration
+	Policy               string
+	PolicyArns           []string
 }
 
 func newS3Option() *S3Option {
 	return &S3Option{
-		Endpoint: os.Getenv(S3EndpointEnvKey), // default from env var
+		Endpoint:       os.Getenv(S3EndpointEnvKey), // default from env var
+		SSECustomerKey: os.Getenv(S3SSECustomerKeyEnvKey),
 	}
 }
 
 func readS3State(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	bucket, key, opt, err := s3StateObject(ctx, config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return readS3(ctx, bucket, key, *opt)
+}
+
+func listS3StateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	bucket, key, opt, err := s3StateObject(ctx, config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return listS3Versions(ctx, bucket, key, *opt)
+}
+
+// s3StateObject returns the bucket, the key and the option of the state object of the s3 backend.
+func s3StateObject(ctx context.Context, config map[string]any, ws string) (string, string, *S3Option, error) {
 	bucket, key := *strpe(config["bucket"]), *strpe(config["key"])
 	if ws != defaultWorkspace {
 		if prefix := strp(config["workspace_key_prefix"]); prefix != nil {
@@ -46,75 +123,428 @@ func readS3State(ctx context.Context, config map[string]any, ws string) (io.Read
 		}
 	}
 	opt := newS3Option()
+	if cfg := readURLConfigFrom(ctx); cfg != nil {
+		opt.Endpoint, opt.SSECustomerKey = cfg.s3Endpoint, cfg.s3SSECustomerKey
+	}
+	versionID, asOf, err := stateVersion(ctx, config)
+	if err != nil {
+		return "", "", nil, err
+	}
+	opt.VersionID, opt.AsOf = versionID, asOf
 	opt.Region = *strpe(config["region"])
 	opt.RoleArn = *strpe(config["role_arn"])
 	opt.AccessKey = *strpe(config["access_key"])
 	opt.SecretKey = *strpe(config["secret_key"])
-	if config["endpoints"] != nil {
-		if es, ok := config["endpoints"].(map[string]any); ok {
-			if es["s3"] != nil {
-				opt.Endpoint = es["s3"].(string)
-			}
+	opt.Token = *strpe(config["token"])
+	opt.Profile = *strpe(config["profile"])
+	if v := *strpe(config["sse_customer_key"]); v != "" {
+		opt.SSECustomerKey = v
+	}
+	opt.SharedConfigFiles = strs(config["shared_config_files"])
+	opt.SharedCredentialsFiles = strs(config["shared_credentials_files"])
+	if f := *strpe(config["shared_credentials_file"]); f != "" {
+		// deprecated
+		opt.SharedCredentialsFiles = append(opt.SharedCredentialsFiles, f)
+	}
+	opt.CustomCABundle = *strpe(config["custom_ca_bundle"])
+
+	// deprecated top level endpoints
+	if v := *strpe(config["endpoint"]); v != "" {
+		opt.Endpoint = v
+	}
+	opt.STSEndpoint = *strpe(config["sts_endpoint"])
+	if es, ok := config["endpoints"].(map[string]any); ok {
+		if v := *strpe(es["s3"]); v != "" {
+			opt.Endpoint = v
 		}
+		if v := *strpe(es["sts"]); v != "" {
+			opt.STSEndpoint = v
+		}
+		// endpoints.iam is not used because tfstate-lookup never calls IAM APIs.
 	}
-	return readS3(ctx, bucket, key, *opt)
+	for k, p := range map[string]*bool{
+		"use_path_style":         &opt.UsePathStyle,
+		"force_path_style":       &opt.UsePathStyle, // deprecated
+		"use_fips_endpoint":      &opt.UseFIPSEndpoint,
+		"use_dualstack_endpoint": &opt.UseDualStackEndpoint,
+		"skip_region_validation": &opt.SkipRegionValidation,
+	} {
+		if v, ok := config[k].(bool); ok && v {
+			*p = true
+		}
+	}
+	// skip_credentials_validation is always in effect because tfstate-lookup
+	// never validates credentials by STS GetCallerIdentity.
+
+	if ar := configBlock(config["assume_role"]); ar != nil {
+		duration, err := parseS3Duration(ar["duration"])
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid assume_role.duration: %w", err)
+		}
+		opt.AssumeRole = &S3AssumeRole{
+			RoleArn:           *strpe(ar["role_arn"]),
+			ExternalID:        *strpe(ar["external_id"]),
+			SessionName:       *strpe(ar["session_name"]),
+			Duration:          duration,
+			Policy:            *strpe(ar["policy"]),
+			PolicyArns:        strs(ar["policy_arns"]),
+			SourceIdentity:    *strpe(ar["source_identity"]),
+			Tags:              strm(ar["tags"]),
+			TransitiveTagKeys: strs(ar["transitive_tag_keys"]),
+		}
+	} else if opt.RoleArn != "" {
+		// deprecated top level assume role attributes
+		opt.AssumeRole = &S3AssumeRole{
+			RoleArn:           opt.RoleArn,
+			ExternalID:        *strpe(config["external_id"]),
+			SessionName:       *strpe(config["session_name"]),
+			Policy:            *strpe(config["assume_role_policy"]),
+			PolicyArns:        strs(config["assume_role_policy_arns"]),
+			Tags:              strm(config["assume_role_tags"]),
+			TransitiveTagKeys: strs(config["assume_role_transitive_tag_keys"]),
+		}
+		if v, ok := config["assume_role_duration_seconds"].(float64); ok {
+			opt.AssumeRole.Duration = time.Duration(v) * time.Second
+		}
+	}
+
+	if wi := configBlock(config["assume_role_with_web_identity"]); wi != nil {
+		duration, err := parseS3Duration(wi["duration"])
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid assume_role_with_web_identity.duration: %w", err)
+		}
+		opt.AssumeRoleWithWebIdentity = &S3AssumeRoleWithWebIdentity{
+			RoleArn:              *strpe(wi["role_arn"]),
+			SessionName:          *strpe(wi["session_name"]),
+			WebIdentityToken:     *strpe(wi["web_identity_token"]),
+			WebIdentityTokenFile: *strpe(wi["web_identity_token_file"]),
+			Duration:             duration,
+			Policy:               *strpe(wi["policy"]),
+			PolicyArns:           strs(wi["policy_arns"]),
+		}
+	}
+	return bucket, key, opt, nil
 }
 
 func readS3(ctx context.Context, bucket, key string, opt S3Option) (io.ReadCloser, error) {
-	var staticProvider aws.CredentialsProvider
-	if opt.AccessKey != "" && opt.SecretKey != "" {
-		staticProvider = credentials.NewStaticCredentialsProvider(opt.AccessKey, opt.SecretKey, "")
+	svc, err := newS3Client(ctx, bucket, opt)
+	if err != nil {
+		return nil, err
+	}
+	versionID := opt.VersionID
+	if versionID == "" && !opt.AsOf.IsZero() {
+		if versionID, err = findS3VersionAt(ctx, svc, bucket, key, opt.AsOf); err != nil {
+			return nil, err
+		}
+	}
+	input, err := newS3GetObjectInput(bucket, key, versionID, opt)
+	if err != nil {
+		return nil, err
+	}
+	result, err := svc.GetObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+// listS3Versions lists the versions of the state object, newest first.
+func listS3Versions(ctx context.Context, bucket, key string, opt S3Option) ([]StateVersionInfo, error) {
+	svc, err := newS3Client(ctx, bucket, opt)
+	if err != nil {
+		return nil, err
+	}
+	var versions []StateVersionInfo
+	p := s3.NewListObjectVersionsPaginator(svc, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list object versions: %w", err)
+		}
+		for _, v := range page.Versions {
+			if aws.ToString(v.Key) != key {
+				continue
+			}
+			versions = append(versions, StateVersionInfo{
+				VersionID:    aws.ToString(v.VersionId),
+				LastModified: aws.ToTime(v.LastModified),
+				IsLatest:     aws.ToBool(v.IsLatest),
+			})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	for i, v := range versions {
+		serial, err := readVersionSerial(func(size int64) (io.ReadCloser, error) {
+			input, err := newS3GetObjectInput(bucket, key, v.VersionID, opt)
+			if err != nil {
+				return nil, err
+			}
+			if size > 0 {
+				input.Range = aws.String(fmt.Sprintf("bytes=0-%d", size-1))
+			}
+			result, err := svc.GetObject(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			return result.Body, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read version %s: %w", v.VersionID, err)
+		}
+		versions[i].Serial = serial
 	}
+	return versions, nil
+}
+
+// findS3VersionAt returns the ID of the newest version at or before t.
+func findS3VersionAt(ctx context.Context, svc *s3.Client, bucket, key string, t time.Time) (string, error) {
+	var found string
+	var foundAt time.Time
+	var deleted bool
+	p := s3.NewListObjectVersionsPaginator(svc, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list object versions: %w", err)
+		}
+		for _, v := range page.Versions {
+			m := aws.ToTime(v.LastModified)
+			if aws.ToString(v.Key) == key && !m.After(t) && m.After(foundAt) {
+				found, foundAt, deleted = aws.ToString(v.VersionId), m, false
+			}
+		}
+		for _, d := range page.DeleteMarkers {
+			m := aws.ToTime(d.LastModified)
+			if aws.ToString(d.Key) == key && !m.After(t) && m.After(foundAt) {
+				found, foundAt, deleted = "", m, true
+			}
+		}
+	}
+	if deleted {
+		return "", fmt.Errorf("s3://%s/%s was deleted at %s", bucket, key, foundAt.Format(time.RFC3339))
+	}
+	if found == "" {
+		return "", fmt.Errorf("no version of s3://%s/%s at or before %s", bucket, key, t.Format(time.RFC3339))
+	}
+	return found, nil
+}
+
+func newS3GetObjectInput(bucket, key, versionID string, opt S3Option) (*s3.GetObjectInput, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if opt.SSECustomerKey != "" {
+		keyMD5, err := s3SSECustomerKeyMD5(opt.SSECustomerKey)
+		if err != nil {
+			return nil, err
+		}
+		input.SSECustomerAlgorithm = aws.String("AES256")
+		input.SSECustomerKey = aws.String(opt.SSECustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(keyMD5)
+	}
+	return input, nil
+}
 
-	cfg, err := config.LoadDefaultConfig(ctx,
+func newS3Client(ctx context.Context, bucket string, opt S3Option) (*s3.Client, error) {
+	loadOpts := []func(*config.LoadOptions) error{
 		config.WithRegion(opt.Region),
-		config.WithCredentialsProvider(staticProvider),
-	)
+	}
+	if opt.AccessKey != "" && opt.SecretKey != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(opt.AccessKey, opt.SecretKey, opt.Token),
+		))
+	}
+	if opt.Profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(opt.Profile))
+	}
+	if len(opt.SharedConfigFiles) > 0 {
+		loadOpts = append(loadOpts, config.WithSharedConfigFiles(expandHomeAll(opt.SharedConfigFiles)))
+	}
+	if len(opt.SharedCredentialsFiles) > 0 {
+		loadOpts = append(loadOpts, config.WithSharedCredentialsFiles(expandHomeAll(opt.SharedCredentialsFiles)))
+	}
+	if opt.UseFIPSEndpoint {
+		loadOpts = append(loadOpts, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if opt.UseDualStackEndpoint {
+		loadOpts = append(loadOpts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	if opt.CustomCABundle != "" {
+		b, err := os.ReadFile(expandHome(opt.CustomCABundle))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom_ca_bundle: %w", err)
+		}
+		loadOpts = append(loadOpts, config.WithCustomCABundle(bytes.NewReader(b)))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// Skip getBucketRegion when using custom endpoint (e.g., MinIO, LocalStack)
-	// as these services don't support the HeadBucket region detection
-	if opt.Endpoint == "" {
+	// as these services don't support the HeadBucket region detection,
+	// and when the region is pinned by the config.
+	if opt.Endpoint == "" && !opt.regionPinned() {
 		region, err := getBucketRegion(ctx, cfg, bucket)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get bucket region: %w", err)
 		}
-		if region != opt.Region {
-			// reload config with bucket region
-			cfg, err = config.LoadDefaultConfig(ctx,
-				config.WithRegion(region),
-			)
-			if err != nil {
-				return nil, err
-			}
+		// use bucket region, keeping the resolved credentials
+		cfg.Region = region
+	}
+
+	// assume roles in the same order as Terraform:
+	// web identity first, then assume_role on top of it.
+	if wi := opt.AssumeRoleWithWebIdentity; wi != nil && wi.RoleArn != "" {
+		provider, err := newS3WebIdentityProvider(newS3STSClient(cfg, opt), *wi)
+		if err != nil {
+			return nil, err
 		}
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	ar := opt.AssumeRole
+	if ar == nil && opt.RoleArn != "" {
+		ar = &S3AssumeRole{RoleArn: opt.RoleArn}
 	}
-	if opt.RoleArn != "" {
-		arn, err := arn.Parse(opt.RoleArn)
+	if ar != nil && ar.RoleArn != "" {
+		provider, err := newS3AssumeRoleProvider(newS3STSClient(cfg, opt), *ar)
 		if err != nil {
 			return nil, err
 		}
-		creds := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), arn.String())
-		cfg.Credentials = creds
+		cfg.Credentials = aws.NewCredentialsCache(provider)
 	}
+
 	s3Opts := []func(*s3.Options){}
 	if u := opt.Endpoint; u != "" {
 		s3Opts = append(s3Opts, func(o *s3.Options) {
 			o.BaseEndpoint = aws.String(u)
 			o.UsePathStyle = true // for localstack, minio, etc compatible services
 		})
+	} else if opt.UsePathStyle {
+		s3Opts = append(s3Opts, func(o *s3.Options) {
+			o.UsePathStyle = true
+		})
 	}
-	svc := s3.NewFromConfig(cfg, s3Opts...)
-	result, err := svc.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+	return s3.NewFromConfig(cfg, s3Opts...), nil
+}
+
+// regionPinned reports whether the bucket region is pinned by the config.
+// FIPS endpoints are regional, so the configured region must be the bucket region.
+func (opt S3Option) regionPinned() bool {
+	return opt.Region != "" && (opt.SkipRegionValidation || opt.UseFIPSEndpoint)
+}
+
+func newS3STSClient(cfg aws.Config, opt S3Option) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if opt.STSEndpoint != "" {
+			o.BaseEndpoint = aws.String(opt.STSEndpoint)
+		}
 	})
+}
+
+func newS3AssumeRoleProvider(client *sts.Client, ar S3AssumeRole) (aws.CredentialsProvider, error) {
+	roleArn, err := arn.Parse(ar.RoleArn)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid assume_role.role_arn: %w", err)
 	}
-	return result.Body, nil
+	return stscreds.NewAssumeRoleProvider(client, roleArn.String(), func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = ar.SessionName
+		o.Duration = ar.Duration
+		o.PolicyARNs = s3PolicyDescriptors(ar.PolicyArns)
+		o.TransitiveTagKeys = ar.TransitiveTagKeys
+		if ar.ExternalID != "" {
+			o.ExternalID = aws.String(ar.ExternalID)
+		}
+		if ar.Policy != "" {
+			o.Policy = aws.String(ar.Policy)
+		}
+		if ar.SourceIdentity != "" {
+			o.SourceIdentity = aws.String(ar.SourceIdentity)
+		}
+		for k, v := range ar.Tags {
+			o.Tags = append(o.Tags, ststypes.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+	}), nil
+}
+
+func newS3WebIdentityProvider(client *sts.Client, wi S3AssumeRoleWithWebIdentity) (aws.CredentialsProvider, error) {
+	roleArn, err := arn.Parse(wi.RoleArn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assume_role_with_web_identity.role_arn: %w", err)
+	}
+	var token stscreds.IdentityTokenRetriever
+	switch {
+	case wi.WebIdentityToken != "":
+		token = s3WebIdentityToken(wi.WebIdentityToken)
+	case wi.WebIdentityTokenFile != "":
+		token = stscreds.IdentityTokenFile(expandHome(wi.WebIdentityTokenFile))
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		token = stscreds.IdentityTokenFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	default:
+		return nil, fmt.Errorf("assume_role_with_web_identity requires web_identity_token or web_identity_token_file")
+	}
+	sessionName := wi.SessionName
+	if sessionName == "" {
+		sessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+	}
+	return stscreds.NewWebIdentityRoleProvider(client, roleArn.String(), token, func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = sessionName
+		o.Duration = wi.Duration
+		o.PolicyARNs = s3PolicyDescriptors(wi.PolicyArns)
+		if wi.Policy != "" {
+			o.Policy = aws.String(wi.Policy)
+		}
+	}), nil
+}
+
+// s3SSECustomerKeyMD5 validates a base64 encoded SSE-C key and returns its base64 encoded MD5 digest.
+func s3SSECustomerKeyMD5(key string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("sse_customer_key must be base64 encoded: %w", err)
+	}
+	if len(b) != 32 {
+		return "", fmt.Errorf("sse_customer_key must be a 256-bit key, got %d bytes", len(b))
+	}
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// s3WebIdentityToken is an inline web identity token.
+type s3WebIdentityToken string
+
+func (t s3WebIdentityToken) GetIdentityToken() ([]byte, error) {
+	return []byte(t), nil
+}
+
+func s3PolicyDescriptors(arns []string) []ststypes.PolicyDescriptorType {
+	var ds []ststypes.PolicyDescriptorType
+	for _, a := range arns {
+		ds = append(ds, ststypes.PolicyDescriptorType{Arn: aws.String(a)})
+	}
+	return ds
+}
+
+// parseS3Duration parses a duration string such as "1h" of assume role blocks.
+func parseS3Duration(v any) (time.Duration, error) {
+	s := *strpe(v)
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
 }
 
 func getBucketRegion(ctx context.Context, cfg aws.Config, bucket string) (string, error) {
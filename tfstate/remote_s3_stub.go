@@ -6,22 +6,68 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 )
 
-const S3EndpointEnvKey = "AWS_ENDPOINT_URL_S3"
+const (
+	S3EndpointEnvKey       = "AWS_ENDPOINT_URL_S3"
+	S3SSECustomerKeyEnvKey = "AWS_SSE_CUSTOMER_KEY"
+)
 
 type S3Option struct {
 	AccessKey string
 	SecretKey string
+	Token     string
 	Region    string
-	RoleArn   string
+	RoleArn   string // deprecated: use AssumeRole.RoleArn
 	Endpoint  string
+
+	STSEndpoint          string
+	UsePathStyle         bool
+	UseFIPSEndpoint      bool
+	UseDualStackEndpoint bool
+	CustomCABundle       string
+	SkipRegionValidation bool
+
+	SSECustomerKey string
+
+	VersionID string
+	AsOf      time.Time
+
+	Profile                string
+	SharedConfigFiles      []string
+	SharedCredentialsFiles []string
+
+	AssumeRole                *S3AssumeRole
+	AssumeRoleWithWebIdentity *S3AssumeRoleWithWebIdentity
+}
+
+type S3AssumeRole struct {
+	RoleArn           string
+	ExternalID        string
+	SessionName       string
+	Duration          time.Duration
+	Policy            string
+	PolicyArns        []string
+	SourceIdentity    string
+	Tags              map[string]string
+	TransitiveTagKeys []string
+}
+
+type S3AssumeRoleWithWebIdentity struct {
+	RoleArn              string
+	SessionName          string
+	WebIdentityToken     string
+	WebIdentityTokenFile string
+	Duration             time.Duration
+	Policy               string
+	PolicyArns           []string
 }
 
 func readS3State(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
 	return nil, fmt.Errorf("S3 backend is not available (built with no_s3 tag)")
 }
 
-func readS3(ctx context.Context, bucket, key string, opt S3Option) (io.ReadCloser, error) {
+func listS3StateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
 	return nil, fmt.Errorf("S3 backend is not available (built with no_s3 tag)")
 }
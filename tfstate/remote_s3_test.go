@@ -1,8 +1,19 @@
 package tfstate_test
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/fujiwara/tfstate-lookup/tfstate"
 )
@@ -72,3 +83,376 @@ func TestReadS3(t *testing.T) {
 		}
 	})
 }
+
+// newFakeAWS returns a fake server acting as both STS and S3 (path style).
+func newFakeAWS(t *testing.T, allowedKey string, objects map[string][]byte) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(fakeAWSHandler(allowedKey, objects))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// fakeAWSHandler serves STS actions and S3 objects.
+// It serves the object only to requests signed by allowedKey.
+func fakeAWSHandler(allowedKey string, objects map[string][]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			action := r.Form.Get("Action")
+			switch action {
+			case "AssumeRole":
+				if r.Form.Get("ExternalId") != "ext-id" || r.Form.Get("Tags.member.1.Key") != "team" {
+					http.Error(w, "unexpected AssumeRole request: "+r.Form.Encode(), http.StatusBadRequest)
+					return
+				}
+			case "AssumeRoleWithWebIdentity":
+				if r.Form.Get("WebIdentityToken") != "web-token" {
+					http.Error(w, "unexpected web identity token", http.StatusBadRequest)
+					return
+				}
+			default:
+				http.Error(w, "unknown action "+action, http.StatusBadRequest)
+				return
+			}
+			// the assumed key is derived from the role name
+			roleArn := r.Form.Get("RoleArn")
+			key := roleArn[strings.LastIndex(roleArn, "/")+1:]
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult>
+<Credentials><AccessKeyId>%[2]s</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>
+<AssumedRoleUser><Arn>%[3]s/session</Arn><AssumedRoleId>id:session</AssumedRoleId></AssumedRoleUser>
+</%[1]sResult></%[1]sResponse>`, action, key, roleArn)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+allowedKey+"/") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		b, ok := objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	})
+}
+
+func TestReadS3BackendAuth(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "credentials"), "[ci]\naws_access_key_id = PROFILEKEY\naws_secret_access_key = secret\n")
+	writeTestFile(t, filepath.Join(dir, "config"), "[profile ci]\nregion = us-west-2\n")
+	writeTestFile(t, filepath.Join(dir, "token"), "web-token")
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", tfstate.S3EndpointEnvKey} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "none"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "none"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	tests := []struct {
+		name       string
+		allowedKey string
+		config     string
+	}{
+		{
+			name:       "static keys with token",
+			allowedKey: "STATICKEY",
+			config:     `{"access_key": "STATICKEY", "secret_key": "secret", "token": "session"}`,
+		},
+		{
+			name:       "profile and shared files",
+			allowedKey: "PROFILEKEY",
+			config:     fmt.Sprintf(`{"profile": "ci", "shared_config_files": [%q], "shared_credentials_files": [%q]}`, filepath.Join(dir, "config"), filepath.Join(dir, "credentials")),
+		},
+		{
+			name:       "assume_role",
+			allowedKey: "ASSUMEDKEY",
+			config:     `{"access_key": "STATICKEY", "secret_key": "secret", "assume_role": {"role_arn": "arn:aws:iam::123456789012:role/ASSUMEDKEY", "external_id": "ext-id", "session_name": "ci", "duration": "1h", "tags": {"team": "infra"}}}`,
+		},
+		{
+			name:       "assume_role_with_web_identity",
+			allowedKey: "WEBIDENTITYKEY",
+			config:     fmt.Sprintf(`{"assume_role_with_web_identity": {"role_arn": "arn:aws:iam::123456789012:role/WEBIDENTITYKEY", "web_identity_token_file": %q}}`, filepath.Join(dir, "token")),
+		},
+		{
+			name:       "assume_role chained on web identity",
+			allowedKey: "CHAINEDKEY",
+			config:     `{"assume_role_with_web_identity": {"role_arn": "arn:aws:iam::123456789012:role/WEBIDENTITYKEY", "web_identity_token": "web-token"}, "assume_role": {"role_arn": "arn:aws:iam::123456789012:role/CHAINEDKEY", "external_id": "ext-id", "tags": {"team": "infra"}}}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newFakeAWS(t, tc.allowedKey, map[string][]byte{"mybucket/terraform.tfstate": b})
+			t.Setenv("AWS_ENDPOINT_URL_STS", ts.URL)
+			config := strings.TrimSuffix(tc.config, "}") +
+				fmt.Sprintf(`, "bucket": "mybucket", "key": "terraform.tfstate", "region": "us-east-1", "endpoints": {"s3": %q}}`, ts.URL)
+			src := fmt.Sprintf(`{"version": 3, "backend": {"type": "s3", "config": %s}}`, config)
+			state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		})
+	}
+}
+
+func TestReadS3SSECustomerKey(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x42}, 32))
+	sum := md5.Sum(bytes.Repeat([]byte{0x42}, 32))
+	keyMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" ||
+			r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key") != key ||
+			r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") != keyMD5 {
+			http.Error(w, "InvalidRequest", http.StatusBadRequest)
+			return
+		}
+		w.Write(b)
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "testkey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "testsecret")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv(tfstate.S3SSECustomerKeyEnvKey, "")
+
+	t.Run("backend config", func(t *testing.T) {
+		src := fmt.Sprintf(`{"version": 3, "backend": {"type": "s3", "config": {"bucket": "mybucket", "key": "terraform.tfstate", "sse_customer_key": %q, "endpoints": {"s3": %q}}}}`, key, ts.URL)
+		state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("URL with S3SSECustomerKeyOption", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), "s3://mybucket/terraform.tfstate",
+			tfstate.S3EndpointOption(ts.URL), tfstate.S3SSECustomerKeyOption(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("URL with env var", func(t *testing.T) {
+		t.Setenv(tfstate.S3SSECustomerKeyEnvKey, key)
+		state, err := tfstate.ReadURL(t.Context(), "s3://mybucket/terraform.tfstate", tfstate.S3EndpointOption(ts.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		_, err := tfstate.ReadURL(t.Context(), "s3://mybucket/terraform.tfstate",
+			tfstate.S3EndpointOption(ts.URL), tfstate.S3SSECustomerKeyOption("c2hvcnQ="))
+		if err == nil {
+			t.Error("expected error for a short key")
+		}
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		if _, err := tfstate.ReadURL(t.Context(), "s3://mybucket/terraform.tfstate", tfstate.S3EndpointOption(ts.URL)); err == nil {
+			t.Error("expected error without SSE-C key")
+		}
+	})
+}
+
+func TestReadS3BackendEndpoints(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := fakeAWSHandler("ASSUMEDKEY", map[string][]byte{"mybucket/terraform.tfstate": b})
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			t.Error("unexpected HeadBucket probe")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		case http.MethodPost:
+			t.Error("unexpected STS request to the S3 endpoint")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	stsServer := httptest.NewTLSServer(handler) // shares the certificate with ts
+	defer stsServer.Close()
+
+	dir := t.TempDir()
+	caBundle := filepath.Join(dir, "ca.pem")
+	writeTestFile(t, caBundle, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})))
+	for _, k := range []string{"AWS_PROFILE", "AWS_CA_BUNDLE", "AWS_ENDPOINT_URL_STS", tfstate.S3EndpointEnvKey} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "STATICKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "none"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "none"))
+	// S3 requests are sent to the fake server without endpoints.s3,
+	// so the bucket region detection is not skipped by a custom S3 endpoint.
+	t.Setenv("AWS_ENDPOINT_URL", ts.URL)
+
+	src := fmt.Sprintf(`{"version": 3, "backend": {"type": "s3", "config": {
+  "bucket": "mybucket",
+  "key": "terraform.tfstate",
+  "region": "us-gov-west-1",
+  "use_path_style": true,
+  "skip_region_validation": true,
+  "skip_credentials_validation": true,
+  "custom_ca_bundle": %q,
+  "endpoints": {"sts": %q, "iam": "https://iam.example.com"},
+  "assume_role": {"role_arn": "arn:aws-us-gov:iam::123456789012:role/ASSUMEDKEY", "external_id": "ext-id", "tags": {"team": "infra"}}
+}}}`, caBundle, stsServer.URL)
+	state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+// newVersionedS3Server returns a fake S3 server with a versioned bucket.
+// The Range headers of the requests to get the versions are recorded to ranges.
+// The serial of v1 is at the end of the state, after a large output.
+func newVersionedS3Server(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	versions := []struct {
+		id, lastModified string
+		serial           int
+	}{
+		{"v3", "2026-01-03T00:00:00.000Z", 3},
+		{"v2", "2026-01-02T00:00:00.000Z", 2},
+		{"v1", "2026-01-01T00:00:00.000Z", 1},
+	}
+	var mu sync.Mutex
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/mybucket" && q.Has("versions"):
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>mybucket</Name><Prefix>%s</Prefix><IsTruncated>false</IsTruncated>`, q.Get("prefix"))
+			for i, v := range versions {
+				fmt.Fprintf(w, `<Version><Key>terraform.tfstate</Key><VersionId>%s</VersionId><IsLatest>%t</IsLatest><LastModified>%s</LastModified></Version>`, v.id, i == 0, v.lastModified)
+			}
+			fmt.Fprint(w, `<Version><Key>terraform.tfstate.backup</Key><VersionId>b1</VersionId><IsLatest>true</IsLatest><LastModified>2026-01-02T00:00:00.000Z</LastModified></Version>`)
+			fmt.Fprint(w, `<DeleteMarker><Key>terraform.tfstate</Key><VersionId>d1</VersionId><IsLatest>false</IsLatest><LastModified>2026-01-01T12:00:00.000Z</LastModified></DeleteMarker>`)
+			fmt.Fprint(w, `</ListVersionsResult>`)
+		case r.URL.Path == "/mybucket/terraform.tfstate":
+			id := q.Get("versionId")
+			if id == "" {
+				id = versions[0].id
+			}
+			for _, v := range versions {
+				if v.id != id {
+					continue
+				}
+				body := fmt.Sprintf(`{"version": 4, "serial": %d, "outputs": {"ws": {"value": %q, "type": "string"}}}`, v.serial, v.id)
+				if v.id == "v1" {
+					body = fmt.Sprintf(`{"version": 4, "outputs": {"ws": {"value": %q, "type": "string"}, "pad": {"value": %q, "type": "string"}}, "serial": %d}`, v.id, strings.Repeat("x", 2048), v.serial)
+				}
+				mu.Lock()
+				ranges = append(ranges, v.id+":"+r.Header.Get("Range"))
+				mu.Unlock()
+				var end int
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=0-%d", &end); err == nil && end+1 < len(body) {
+					body = body[:end+1]
+					w.WriteHeader(http.StatusPartialContent)
+				}
+				fmt.Fprint(w, body)
+				return
+			}
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &ranges
+}
+
+func TestReadS3Versions(t *testing.T) {
+	ts, ranges := newVersionedS3Server(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "testkey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "testsecret")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv(tfstate.S3EndpointEnvKey, ts.URL)
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     []tfstate.ReadURLOption
+		expected string
+	}{
+		{"latest", "s3://mybucket/terraform.tfstate", nil, "v3"},
+		{"versionId", "s3://mybucket/terraform.tfstate?versionId=v1", nil, "v1"},
+		{"asOf", "s3://mybucket/terraform.tfstate?asOf=2026-01-02T12:00:00Z", nil, "v2"},
+		{"asOf exact", "s3://mybucket/terraform.tfstate?asOf=2026-01-03T00:00:00Z", nil, "v3"},
+		{"VersionIDOption", "s3://mybucket/terraform.tfstate?versionId=v1", []tfstate.ReadURLOption{tfstate.VersionIDOption("v2")}, "v2"},
+		{"AsOfOption", "s3://mybucket/terraform.tfstate", []tfstate.ReadURLOption{tfstate.AsOfOption(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC))}, "v1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := tfstate.ReadURL(t.Context(), tc.url, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj, err := state.Lookup("output.ws")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.String() != tc.expected {
+				t.Errorf("unexpected version %s, expected %s", obj.String(), tc.expected)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"s3://mybucket/terraform.tfstate?asOf=2025-12-31T00:00:00Z", // before the first version
+		"s3://mybucket/terraform.tfstate?asOf=2026-01-01T18:00:00Z", // deleted
+		"s3://mybucket/terraform.tfstate?asOf=yesterday",
+	} {
+		if _, err := tfstate.ReadURL(t.Context(), u); err == nil {
+			t.Errorf("expected error for %s", u)
+		}
+	}
+
+	t.Run("ListStateVersions", func(t *testing.T) {
+		*ranges = nil
+		versions, err := tfstate.ListStateVersions(t.Context(), "s3://mybucket/terraform.tfstate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 3 {
+			t.Fatalf("unexpected versions %v", versions)
+		}
+		for i, v := range versions {
+			if expected := fmt.Sprintf("v%d", 3-i); v.VersionID != expected {
+				t.Errorf("unexpected version id %s, expected %s", v.VersionID, expected)
+			}
+			if v.Serial != int64(3-i) {
+				t.Errorf("unexpected serial %d of %s", v.Serial, v.VersionID)
+			}
+			if v.IsLatest != (i == 0) {
+				t.Errorf("unexpected is_latest %t of %s", v.IsLatest, v.VersionID)
+			}
+		}
+		if !versions[0].LastModified.Equal(time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected last modified %s", versions[0].LastModified)
+		}
+		// only the beginning of the versions is read, and the whole of v1 whose serial is not in it
+		expected := "v3:bytes=0-1023,v2:bytes=0-1023,v1:bytes=0-1023,v1:"
+		if got := strings.Join(*ranges, ","); got != expected {
+			t.Errorf("unexpected requests %s, expected %s", got, expected)
+		}
+	})
+}
@@ -3,66 +3,524 @@
 package tfstate
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	tfe "github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/hcl"
 )
 
+// tfeScheme is the URL scheme to access hostname of TFE. It is replaced in tests.
+var tfeScheme = "https"
+
+// tfeDefaultHostname is the hostname of HCP Terraform.
+const tfeDefaultHostname = "app.terraform.io"
+
+// tfeWorkspace is the workspace of TFE to read the state.
+type tfeWorkspace struct {
+	hostname     string
+	organization string
+	name         string
+	token        string
+}
+
 func readTFEState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
-	hostname, organization, token := *strpe(config["hostname"]), *strp(config["organization"]), *strpe(config["token"])
-	if token == "" {
-		token = os.Getenv("TFE_TOKEN")
+	w, err := tfeStateWorkspace(config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return readTFEWorkspaceState(ctx, config, w)
+}
+
+func listTFEStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	w, err := tfeStateWorkspace(config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return listTFEVersions(ctx, w.hostname, w.organization, w.name, w.token)
+}
+
+// tfeStateWorkspace returns the workspace of the remote backend.
+func tfeStateWorkspace(config map[string]any, ws string) (tfeWorkspace, error) {
+	w := tfeWorkspace{
+		hostname:     *strpe(config["hostname"]),
+		organization: *strpe(config["organization"]),
+		token:        *strpe(config["token"]),
+	}
+	if w.token == "" {
+		w.token = os.Getenv("TFE_TOKEN")
 	}
 
 	workspaces, ok := config["workspaces"].(map[string]any)
 	if !ok {
-		return nil, fmt.Errorf("failed to parse workspaces")
+		return w, fmt.Errorf("failed to parse workspaces")
 	}
 
 	name, prefix := *strpe(workspaces["name"]), *strpe(workspaces["prefix"])
-	if name != "" {
-		return readTFE(ctx, hostname, organization, name, token)
+	switch {
+	case name != "":
+		w.name = name
+	case prefix != "":
+		w.name = prefix + ws
+	default:
+		return w, fmt.Errorf("workspaces requires either name or prefix")
 	}
+	return w, nil
+}
 
-	if prefix != "" {
-		return readTFE(ctx, hostname, organization, prefix+ws, token)
+func readTFECloudState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	w, err := tfeCloudStateWorkspace(config, ws)
+	if err != nil {
+		return nil, err
 	}
+	return readTFEWorkspaceState(ctx, config, w)
+}
 
-	return nil, fmt.Errorf("workspaces requires either name or prefix")
+func listTFECloudStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	w, err := tfeCloudStateWorkspace(config, ws)
+	if err != nil {
+		return nil, err
+	}
+	return listTFEVersions(ctx, w.hostname, w.organization, w.name, w.token)
 }
 
-func readTFE(ctx context.Context, hostname string, organization string, ws string, token string) (io.ReadCloser, error) {
-	var address string
-	address = tfe.DefaultAddress
-	if hostname != "" {
-		address = "https://" + hostname
+// tfeCloudStateWorkspace returns the workspace of the cloud block.
+func tfeCloudStateWorkspace(config map[string]any, ws string) (tfeWorkspace, error) {
+	w := tfeWorkspace{
+		hostname:     *strpe(config["hostname"]),
+		organization: *strpe(config["organization"]),
+		token:        *strpe(config["token"]),
+	}
+	if w.hostname == "" {
+		w.hostname = os.Getenv("TF_CLOUD_HOSTNAME")
+	}
+	if w.organization == "" {
+		w.organization = os.Getenv("TF_CLOUD_ORGANIZATION")
+	}
+	if w.organization == "" {
+		return w, fmt.Errorf("cloud backend requires organization or TF_CLOUD_ORGANIZATION")
+	}
+	if w.token == "" {
+		w.token = os.Getenv("TFE_TOKEN")
+	}
+
+	workspaces, _ := config["workspaces"].(map[string]any)
+	if name := *strpe(workspaces["name"]); name != "" {
+		w.name = name
+		return w, nil
+	}
+	if name := os.Getenv("TF_WORKSPACE"); name != "" {
+		w.name = name
+		return w, nil
+	}
+	// With workspaces.tags (and optionally workspaces.project), the local workspace name is
+	// the name of the workspace in HCP Terraform.
+	if workspaces["tags"] != nil || workspaces["project"] != nil || os.Getenv("TF_CLOUD_PROJECT") != "" {
+		if ws == defaultWorkspace {
+			return w, fmt.Errorf("cloud backend with workspaces.tags requires a selected workspace (TF_WORKSPACE or terraform workspace select)")
+		}
+		w.name = ws
+		return w, nil
 	}
+	return w, fmt.Errorf("cloud backend requires workspaces.name, workspaces.tags or TF_WORKSPACE")
+}
+
+// readTFEWorkspaceState reads the state of the workspace selected by the config.
+func readTFEWorkspaceState(ctx context.Context, config map[string]any, w tfeWorkspace) (io.ReadCloser, error) {
+	opt, err := newTFEOption(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return readTFE(ctx, w.hostname, w.organization, w.name, w.token, opt)
+}
+
+// tfeOption selects a state version of the workspace. The current state version is read when no version is selected.
+type tfeOption struct {
+	stateVersionID string
+	serial         *int64
+	runID          string
+	asOf           time.Time
+
+	// outputsOnly reads only the outputs of the state version instead of downloading the state.
+	outputsOnly bool
+}
+
+// newTFEOption returns the option to select the state version by the options of ReadURL,
+// and version_id, serial, run_id, as_of and outputs_only of the config converted from the URL query.
+func newTFEOption(ctx context.Context, config map[string]any) (tfeOption, error) {
+	versionID, asOf, err := stateVersion(ctx, config)
+	if err != nil {
+		return tfeOption{}, err
+	}
+	opt := tfeOption{
+		stateVersionID: versionID,
+		runID:          *strpe(config["run_id"]),
+		asOf:           asOf,
+	}
+	if v := *strpe(config["outputs_only"]); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return tfeOption{}, fmt.Errorf("invalid outputsOnly %q: %w", v, err)
+		}
+		opt.outputsOnly = b
+	}
+	if v := *strpe(config["serial"]); v != "" {
+		serial, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return tfeOption{}, fmt.Errorf("invalid serial %q: %w", v, err)
+		}
+		opt.serial = &serial
+	}
+	return opt, nil
+}
 
-	var client *tfe.Client
-	client, err := tfe.NewClient(&tfe.Config{
+func (opt tfeOption) selected() bool {
+	return opt.stateVersionID != "" || opt.serial != nil || opt.runID != "" || !opt.asOf.IsZero()
+}
+
+func newTFEClient(hostname string, token string) (*tfe.Client, error) {
+	address := tfe.DefaultAddress
+	if hostname != "" {
+		address = tfeScheme + "://" + hostname
+	}
+	return tfe.NewClient(&tfe.Config{
 		Address: address,
 		Token:   token,
 	})
+}
+
+func readTFE(ctx context.Context, hostname string, organization string, ws string, token string, opt tfeOption) (io.ReadCloser, error) {
+	token, err := resolveTFEToken(hostname, token)
 	if err != nil {
 		return nil, err
 	}
+	client, err := newTFEClient(hostname, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var workspace *tfe.Workspace
+	var state *tfe.StateVersion
+	switch {
+	case opt.stateVersionID != "":
+		state, err = readTFEStateVersion(ctx, client, organization, ws, opt.stateVersionID)
+	case opt.selected():
+		state, err = findTFEStateVersion(ctx, client, organization, ws, opt)
+	default:
+		if workspace, err = client.Workspaces.Read(ctx, organization, ws); err != nil {
+			return nil, err
+		}
+		if opt.outputsOnly {
+			return readTFEOutputs(ctx, client, workspace, nil)
+		}
+		state, err = client.StateVersions.ReadCurrent(ctx, workspace.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !opt.outputsOnly {
+		src, err := downloadTFEState(ctx, state.DownloadURL, token)
+		if !errors.Is(err, errTFEStateForbidden) {
+			return src, err
+		}
+		// The token may be permitted to read only the outputs (e.g. "read outputs" access of HCP Terraform).
+	}
+	return readTFEOutputs(ctx, client, workspace, state)
+}
+
+// tfeStateVersionWorkspace is the workspace relationship of a state version,
+// which tfe.StateVersion does not decode.
+type tfeStateVersionWorkspace struct {
+	ID        string         `jsonapi:"primary,state-versions"`
+	Workspace *tfe.Workspace `jsonapi:"relation,workspace"`
+}
 
+// readTFEStateVersion reads the state version by the ID, which must belong to the workspace.
+func readTFEStateVersion(ctx context.Context, client *tfe.Client, organization string, ws string, id string) (*tfe.StateVersion, error) {
 	workspace, err := client.Workspaces.Read(ctx, organization, ws)
 	if err != nil {
 		return nil, err
 	}
-	state, err := client.StateVersions.ReadCurrent(ctx, workspace.ID)
+	req, err := client.NewRequest(http.MethodGet, "state-versions/"+url.PathEscape(id), nil)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, state.DownloadURL, nil)
+	var sv tfeStateVersionWorkspace
+	if err := req.Do(ctx, &sv); err != nil {
+		return nil, err
+	}
+	if sv.Workspace == nil || sv.Workspace.ID != workspace.ID {
+		return nil, fmt.Errorf("state version %s does not belong to the workspace %s/%s", id, organization, ws)
+	}
+	return client.StateVersions.Read(ctx, id)
+}
+
+var errTFEStateForbidden = errors.New("downloading the state is forbidden")
+
+func downloadTFEState(ctx context.Context, downloadURL string, token string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
-	return readHTTPWithRequest(ctx, req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		resp.Body.Close()
+		return nil, errTFEStateForbidden
+	case resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download the state: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// readTFEOutputs builds a state which contains only the outputs of the state version.
+// The current-state-version-outputs endpoint of the workspace is used when the workspace is given,
+// because the token with outputs only permission may not read the current state version itself.
+//
+// Values of sensitive outputs are redacted in the list, so each of them is read individually.
+// A sensitive output which the token is not permitted to read is an error.
+func readTFEOutputs(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace, state *tfe.StateVersion) (io.ReadCloser, error) {
+	var items []*tfe.StateVersionOutput
+	if workspace != nil {
+		list, err := client.StateVersionOutputs.ReadCurrent(ctx, workspace.ID)
+		if err != nil {
+			return nil, err
+		}
+		items = list.Items
+	} else {
+		opts := &tfe.StateVersionOutputsListOptions{ListOptions: tfe.ListOptions{PageSize: 100}}
+		for {
+			list, err := client.StateVersions.ListOutputs(ctx, state.ID, opts)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, list.Items...)
+			if list.Pagination == nil || list.NextPage == 0 {
+				break
+			}
+			opts.PageNumber = list.NextPage
+		}
+	}
+
+	outputs := make(map[string]any, len(items))
+	for _, o := range items {
+		value := o.Value
+		if o.Sensitive && value == nil {
+			so, err := client.StateVersionOutputs.Read(ctx, o.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the sensitive output %s: %w", o.Name, err)
+			}
+			if so.Value == nil {
+				return nil, fmt.Errorf("the value of the sensitive output %s is not readable", o.Name)
+			}
+			value = so.Value
+		}
+		var typ any = o.Type
+		if o.DetailedType != nil {
+			typ = o.DetailedType
+		}
+		outputs[o.Name] = map[string]any{
+			"value":     value,
+			"type":      typ,
+			"sensitive": o.Sensitive,
+		}
+	}
+	s := map[string]any{
+		"version": StateVersion,
+		"outputs": outputs,
+	}
+	if state != nil {
+		s["serial"] = state.Serial
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// findTFEStateVersion finds the state version selected by the serial, the run ID or the time.
+// State versions are listed newest first, so the first match of asOf is the newest version at or before the time.
+func findTFEStateVersion(ctx context.Context, client *tfe.Client, organization string, ws string, opt tfeOption) (*tfe.StateVersion, error) {
+	var found *tfe.StateVersion
+	err := tfeStateVersions(ctx, client, organization, ws, func(sv *tfe.StateVersion) bool {
+		switch {
+		case opt.serial != nil:
+			if sv.Serial == *opt.serial {
+				found = sv
+			}
+		case opt.runID != "":
+			if sv.Run != nil && sv.Run.ID == opt.runID {
+				found = sv
+			}
+		case !opt.asOf.IsZero():
+			if !sv.CreatedAt.After(opt.asOf) {
+				found = sv
+			}
+		}
+		return found == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		switch {
+		case opt.serial != nil:
+			return nil, fmt.Errorf("state version of serial %d is not found in workspace %s/%s", *opt.serial, organization, ws)
+		case opt.runID != "":
+			return nil, fmt.Errorf("state version of run %s is not found in workspace %s/%s", opt.runID, organization, ws)
+		default:
+			return nil, fmt.Errorf("no state version at or before %s in workspace %s/%s", opt.asOf.Format(time.RFC3339), organization, ws)
+		}
+	}
+	return found, nil
+}
+
+// tfeStateVersions calls fn for each state version of the workspace, newest first, until fn returns false.
+func tfeStateVersions(ctx context.Context, client *tfe.Client, organization string, ws string, fn func(*tfe.StateVersion) bool) error {
+	opts := &tfe.StateVersionListOptions{
+		ListOptions:  tfe.ListOptions{PageSize: 100},
+		Organization: organization,
+		Workspace:    ws,
+	}
+	for {
+		list, err := client.StateVersions.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, sv := range list.Items {
+			if !fn(sv) {
+				return nil
+			}
+		}
+		if list.Pagination == nil || list.NextPage == 0 {
+			return nil
+		}
+		opts.PageNumber = list.NextPage
+	}
+}
+
+func listTFEVersions(ctx context.Context, hostname string, organization string, ws string, token string) ([]StateVersionInfo, error) {
+	token, err := resolveTFEToken(hostname, token)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newTFEClient(hostname, token)
+	if err != nil {
+		return nil, err
+	}
+	var versions []StateVersionInfo
+	err = tfeStateVersions(ctx, client, organization, ws, func(sv *tfe.StateVersion) bool {
+		v := StateVersionInfo{
+			VersionID:    sv.ID,
+			Serial:       sv.Serial,
+			LastModified: sv.CreatedAt,
+			IsLatest:     len(versions) == 0,
+		}
+		if sv.Run != nil {
+			v.RunID = sv.Run.ID
+		}
+		versions = append(versions, v)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// resolveTFEToken returns the token if it is specified, otherwise finds the token for the hostname
+// in the same order as Terraform:
+//
+//  1. TF_TOKEN_{hostname} environment variable (periods are encoded as "_" and hyphens as "__")
+//  2. credentials.tfrc.json in the CLI config directory, written by terraform login
+//  3. credentials blocks of the CLI config file (TF_CLI_CONFIG_FILE or ~/.terraformrc)
+func resolveTFEToken(hostname string, token string) (string, error) {
+	if token != "" {
+		return token, nil
+	}
+	if hostname == "" {
+		hostname = tfeDefaultHostname
+	}
+	hostname = strings.ToLower(hostname)
+
+	for _, env := range os.Environ() {
+		k, v, _ := strings.Cut(env, "=")
+		name, ok := strings.CutPrefix(k, "TF_TOKEN_")
+		if !ok || v == "" {
+			continue
+		}
+		name = strings.ReplaceAll(strings.ReplaceAll(name, "__", "-"), "_", ".")
+		if strings.ToLower(name) == hostname {
+			return v, nil
+		}
+	}
+
+	configFile := os.Getenv("TF_CLI_CONFIG_FILE")
+	configDir := ""
+	if runtime.GOOS == "windows" {
+		configDir = filepath.Join(os.Getenv("APPDATA"), "terraform.d")
+		if configFile == "" {
+			configFile = filepath.Join(os.Getenv("APPDATA"), "terraform.rc")
+		}
+	} else {
+		configDir = expandHome("~/.terraform.d")
+		if configFile == "" {
+			configFile = expandHome("~/.terraformrc")
+		}
+	}
+	for _, f := range []string{filepath.Join(configDir, "credentials.tfrc.json"), configFile} {
+		token, err := readTFECredentials(f, hostname)
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+	return "", nil
+}
+
+// readTFECredentials reads the token for the hostname from credentials blocks of the CLI config file.
+// The file is written in HCL or JSON, and a missing file is not an error.
+func readTFECredentials(path string, hostname string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var config struct {
+		Credentials map[string]map[string]any `hcl:"credentials"`
+	}
+	if err := hcl.Unmarshal(b, &config); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for host, c := range config.Credentials {
+		if strings.ToLower(host) != hostname {
+			continue
+		}
+		if token, ok := c["token"].(string); ok {
+			return token, nil
+		}
+	}
+	return "", nil
 }
@@ -12,6 +12,14 @@ func readTFEState(ctx context.Context, config map[string]any, ws string) (io.Rea
 	return nil, fmt.Errorf("TFE backend is not available (built with no_tfe tag)")
 }
 
-func readTFE(ctx context.Context, hostname string, organization string, ws string, token string) (io.ReadCloser, error) {
+func readTFECloudState(ctx context.Context, config map[string]any, ws string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("TFE backend is not available (built with no_tfe tag)")
+}
+
+func listTFEStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
+	return nil, fmt.Errorf("TFE backend is not available (built with no_tfe tag)")
+}
+
+func listTFECloudStateVersions(ctx context.Context, config map[string]any, ws string) ([]StateVersionInfo, error) {
 	return nil, fmt.Errorf("TFE backend is not available (built with no_tfe tag)")
 }
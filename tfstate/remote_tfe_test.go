@@ -0,0 +1,518 @@
+//go:build !no_tfe
+
+package tfstate_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// fakeTFE is a minimal HCP Terraform / Terraform Enterprise API server.
+type fakeTFE struct {
+	*httptest.Server
+	token      string
+	workspaces map[string]string // "org/name" -> workspace ID
+	state      []byte
+
+	// stateVersions are the state versions of all workspaces, newest first.
+	stateVersions []fakeTFEStateVersion
+
+	// outputs are the outputs of all state versions. Downloading the states is forbidden when forbidState is true.
+	outputs     []fakeTFEOutput
+	forbidState bool
+
+	// currentStateStatus is the error status of reading the current state version if not 0.
+	currentStateStatus int
+}
+
+type fakeTFEOutput struct {
+	id        string
+	name      string
+	typ       string
+	value     any
+	sensitive bool
+	readable  bool // the value of a sensitive output is readable by the token
+	status    int  // the error status of reading the output if not 0
+}
+
+func writeTFEError(w http.ResponseWriter, status int) {
+	http.Error(w, fmt.Sprintf(`{"errors":[{"status":"%d","title":%q}]}`, status, strings.ToLower(http.StatusText(status))), status)
+}
+
+func (f *fakeTFE) outputData(o fakeTFEOutput, redact bool) map[string]any {
+	value := o.value
+	if o.sensitive && redact {
+		value = nil
+	}
+	return map[string]any{
+		"id":   o.id,
+		"type": "state-version-outputs",
+		"attributes": map[string]any{
+			"name":      o.name,
+			"sensitive": o.sensitive,
+			"type":      o.typ,
+			"value":     value,
+		},
+	}
+}
+
+func (f *fakeTFE) listOutputs(w http.ResponseWriter) {
+	data := []any{}
+	for _, o := range f.outputs {
+		data = append(data, f.outputData(o, true))
+	}
+	f.writeJSONAPI(w, map[string]any{"data": data})
+}
+
+type fakeTFEStateVersion struct {
+	id        string
+	workspace string // "org/name"
+	serial    int
+	runID     string
+	createdAt string
+}
+
+// fakeTFEPageSize is the page size of listing state versions, to exercise the pagination.
+const fakeTFEPageSize = 2
+
+func (f *fakeTFE) stateVersionData(v fakeTFEStateVersion) map[string]any {
+	return map[string]any{
+		"id":   v.id,
+		"type": "state-versions",
+		"attributes": map[string]any{
+			"serial":                    v.serial,
+			"created-at":                v.createdAt,
+			"hosted-state-download-url": f.URL + "/state/" + v.id,
+		},
+		"relationships": map[string]any{
+			"run":       map[string]any{"data": map[string]any{"id": v.runID, "type": "runs"}},
+			"workspace": map[string]any{"data": map[string]any{"id": f.workspaces[v.workspace], "type": "workspaces"}},
+		},
+	}
+}
+
+func (f *fakeTFE) listStateVersions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ws := q.Get("filter[organization][name]") + "/" + q.Get("filter[workspace][name]")
+	var data []any
+	for _, v := range f.stateVersions {
+		if v.workspace == ws {
+			data = append(data, f.stateVersionData(v))
+		}
+	}
+	page, _ := strconv.Atoi(q.Get("page[number]"))
+	page = max(page, 1)
+	total := (len(data) + fakeTFEPageSize - 1) / fakeTFEPageSize
+	next := 0
+	if page < total {
+		next = page + 1
+	}
+	data = data[min((page-1)*fakeTFEPageSize, len(data)):min(page*fakeTFEPageSize, len(data))]
+	f.writeJSONAPI(w, map[string]any{
+		"data": data,
+		"meta": map[string]any{
+			"pagination": map[string]any{"current-page": page, "next-page": next, "total-pages": total},
+		},
+	})
+}
+
+func newFakeTFE(t *testing.T, token string, workspaces map[string]string) *fakeTFE {
+	t.Helper()
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeTFE{token: token, workspaces: workspaces, state: b}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+	t.Cleanup(tfstate.SetTFEScheme("http"))
+	return f
+}
+
+func (f *fakeTFE) Host() string {
+	u, _ := url.Parse(f.URL)
+	return u.Host
+}
+
+func (f *fakeTFE) writeJSONAPI(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeTFE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v2/ping" {
+		w.Header().Set("TFP-API-Version", "2.5")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		http.Error(w, `{"errors":[{"status":"401","title":"unauthorized"}]}`, http.StatusUnauthorized)
+		return
+	}
+	p := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case len(p) == 6 && p[2] == "organizations" && p[4] == "workspaces":
+		id, ok := f.workspaces[p[3]+"/"+p[5]]
+		if !ok {
+			http.Error(w, `{"errors":[{"status":"404","title":"not found"}]}`, http.StatusNotFound)
+			return
+		}
+		f.writeJSONAPI(w, map[string]any{
+			"data": map[string]any{
+				"id":         id,
+				"type":       "workspaces",
+				"attributes": map[string]any{"name": p[5]},
+			},
+		})
+	case len(p) == 5 && p[2] == "workspaces" && p[4] == "current-state-version-outputs",
+		len(p) == 5 && p[2] == "state-versions" && p[4] == "outputs":
+		f.listOutputs(w)
+	case len(p) == 4 && p[2] == "state-version-outputs":
+		for _, o := range f.outputs {
+			if o.id == p[3] && o.status != 0 {
+				writeTFEError(w, o.status)
+				return
+			}
+			if o.id == p[3] && (!o.sensitive || o.readable) {
+				f.writeJSONAPI(w, map[string]any{"data": f.outputData(o, false)})
+				return
+			}
+		}
+		http.Error(w, `{"errors":[{"status":"403","title":"forbidden"}]}`, http.StatusForbidden)
+	case len(p) == 5 && p[2] == "workspaces" && p[4] == "current-state-version" && f.currentStateStatus != 0:
+		writeTFEError(w, f.currentStateStatus)
+	case len(p) == 5 && p[2] == "workspaces" && p[4] == "current-state-version":
+		f.writeJSONAPI(w, map[string]any{
+			"data": map[string]any{
+				"id":   "sv-" + p[3],
+				"type": "state-versions",
+				"attributes": map[string]any{
+					"serial":                    173,
+					"hosted-state-download-url": f.URL + "/state/sv-" + p[3],
+				},
+			},
+		})
+	case len(p) == 3 && p[2] == "state-versions":
+		f.listStateVersions(w, r)
+	case len(p) == 4 && p[2] == "state-versions":
+		for _, v := range f.stateVersions {
+			if v.id == p[3] {
+				f.writeJSONAPI(w, map[string]any{"data": f.stateVersionData(v)})
+				return
+			}
+		}
+		http.Error(w, `{"errors":[{"status":"404","title":"not found"}]}`, http.StatusNotFound)
+	case len(p) == 2 && p[0] == "state" && f.forbidState:
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case len(p) == 2 && p[0] == "state":
+		for _, v := range f.stateVersions {
+			if v.id == p[1] {
+				fmt.Fprintf(w, `{"version": 4, "serial": %d, "outputs": {"sv": {"value": %q, "type": "string"}}}`, v.serial, v.id)
+				return
+			}
+		}
+		w.Write(f.state)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestReadTFECloudBackend(t *testing.T) {
+	f := newFakeTFE(t, "secret-token", map[string]string{
+		"myorg/app":         "ws-app",
+		"myorg/app-staging": "ws-app-staging",
+	})
+	t.Setenv("TFE_TOKEN", "secret-token")
+	t.Setenv("TF_WORKSPACE", "")
+
+	tests := []struct {
+		name   string
+		config string
+		ws     string
+		env    map[string]string
+	}{
+		{
+			name:   "workspaces.name",
+			config: `{"hostname": %q, "organization": "myorg", "workspaces": {"name": "app"}}`,
+			ws:     "default",
+		},
+		{
+			name:   "workspaces.tags",
+			config: `{"hostname": %q, "organization": "myorg", "workspaces": {"tags": ["app"]}}`,
+			ws:     "app-staging",
+		},
+		{
+			name:   "env vars",
+			config: `{"hostname": %q}`,
+			ws:     "default",
+			env:    map[string]string{"TF_CLOUD_ORGANIZATION": "myorg", "TF_WORKSPACE": "app"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			src := fmt.Sprintf(`{"version": 3, "backend": {"type": "cloud", "config": %s}}`, fmt.Sprintf(tc.config, f.Host()))
+			state, err := tfstate.ReadWithWorkspace(t.Context(), strings.NewReader(src), tc.ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		})
+	}
+
+	t.Run("tags without selected workspace", func(t *testing.T) {
+		src := fmt.Sprintf(`{"version": 3, "backend": {"type": "cloud", "config": {"hostname": %q, "organization": "myorg", "workspaces": {"tags": ["app"]}}}}`, f.Host())
+		if _, err := tfstate.Read(t.Context(), strings.NewReader(src)); err == nil {
+			t.Error("expected error without a selected workspace")
+		}
+	})
+}
+
+func TestReadTFEStateVersions(t *testing.T) {
+	f := newFakeTFE(t, "secret-token", map[string]string{"myorg/app": "ws-app"})
+	f.stateVersions = []fakeTFEStateVersion{
+		{"sv-3", "myorg/app", 3, "run-3", "2026-01-03T00:00:00Z"},
+		{"sv-other", "myorg/other", 9, "run-other", "2026-01-02T18:00:00Z"},
+		{"sv-2", "myorg/app", 2, "run-2", "2026-01-02T00:00:00Z"},
+		{"sv-1", "myorg/app", 1, "run-1", "2026-01-01T00:00:00Z"},
+	}
+	t.Setenv("TFE_TOKEN", "secret-token")
+	base := "remote://" + f.Host() + "/myorg/app"
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     []tfstate.ReadURLOption
+		expected string
+	}{
+		{"versionId", base + "?versionId=sv-2", nil, "sv-2"},
+		{"serial", base + "?serial=1", nil, "sv-1"},
+		{"run", base + "?run=run-2", nil, "sv-2"},
+		{"asOf", base + "?asOf=2026-01-02T12:00:00Z", nil, "sv-2"},
+		{"VersionIDOption", base, []tfstate.ReadURLOption{tfstate.VersionIDOption("sv-3")}, "sv-3"},
+		{"AsOfOption", base, []tfstate.ReadURLOption{tfstate.AsOfOption(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC))}, "sv-1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := tfstate.ReadURL(t.Context(), tc.url, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj, err := state.Lookup("output.sv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.String() != tc.expected {
+				t.Errorf("unexpected state version %s, expected %s", obj.String(), tc.expected)
+			}
+		})
+	}
+
+	t.Run("current", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	})
+
+	for _, u := range []string{
+		base + "?versionId=sv-other",        // version of another workspace
+		base + "?serial=9",                  // serial of another workspace
+		base + "?run=run-4",                 // no such run
+		base + "?asOf=2025-12-31T00:00:00Z", // before the first version
+		base + "?serial=latest",
+	} {
+		if _, err := tfstate.ReadURL(t.Context(), u); err == nil {
+			t.Errorf("expected error for %s", u)
+		}
+	}
+
+	t.Run("ListStateVersions", func(t *testing.T) {
+		versions, err := tfstate.ListStateVersions(t.Context(), base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 3 {
+			t.Fatalf("unexpected versions %v", versions)
+		}
+		for i, v := range versions {
+			n := 3 - i
+			if v.VersionID != fmt.Sprintf("sv-%d", n) || v.Serial != int64(n) || v.RunID != fmt.Sprintf("run-%d", n) {
+				t.Errorf("unexpected version %#v", v)
+			}
+			if expected := time.Date(2026, 1, n, 0, 0, 0, 0, time.UTC); !v.LastModified.Equal(expected) {
+				t.Errorf("unexpected created-at %s of %s", v.LastModified, v.VersionID)
+			}
+			if v.IsLatest != (i == 0) {
+				t.Errorf("unexpected IsLatest %t of %s", v.IsLatest, v.VersionID)
+			}
+		}
+	})
+}
+
+func TestReadTFEOutputsOnly(t *testing.T) {
+	f := newFakeTFE(t, "secret-token", map[string]string{"myorg/app": "ws-app"})
+	f.stateVersions = []fakeTFEStateVersion{
+		{"sv-1", "myorg/app", 1, "run-1", "2026-01-01T00:00:00Z"},
+	}
+	f.outputs = []fakeTFEOutput{
+		{id: "wsout-1", name: "foo", typ: "string", value: "FOO"},
+		{id: "wsout-2", name: "list", typ: "array", value: []any{"a", "b"}},
+		{id: "wsout-3", name: "secret", typ: "string", value: "s3cr3t", sensitive: true, readable: true},
+	}
+	t.Setenv("TFE_TOKEN", "secret-token")
+	base := "remote://" + f.Host() + "/myorg/app"
+
+	testOutputs := func(t *testing.T, state *tfstate.TFState) {
+		t.Helper()
+		for key, expected := range map[string]string{
+			"output.foo":     "FOO",
+			"output.list[1]": "b",
+			"output.secret":  "s3cr3t",
+		} {
+			obj, err := state.Lookup(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.String() != expected {
+				t.Errorf("unexpected %s: %s, expected %s", key, obj.String(), expected)
+			}
+		}
+		names, err := state.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(names, ",") != "output.foo,output.list,output.secret" {
+			t.Errorf("unexpected names %v", names)
+		}
+	}
+
+	t.Run("outputsOnly", func(t *testing.T) {
+		state, err := tfstate.ReadURL(t.Context(), base+"?outputsOnly=true")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testOutputs(t, state)
+	})
+
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		t.Run(fmt.Sprintf("current state version %d", status), func(t *testing.T) {
+			f.currentStateStatus = status
+			defer func() { f.currentStateStatus = 0 }()
+			if _, err := tfstate.ReadURL(t.Context(), base); err == nil {
+				t.Error("expected error for the current state version which failed to read")
+			}
+		})
+	}
+
+	for _, o := range []fakeTFEOutput{
+		{id: "wsout-4", name: "hidden", typ: "string", value: "h1dden", sensitive: true},
+		{id: "wsout-5", name: "broken", typ: "string", value: "x", sensitive: true, status: http.StatusInternalServerError},
+	} {
+		t.Run("sensitive output "+o.name, func(t *testing.T) {
+			orig := f.outputs
+			defer func() { f.outputs = orig }()
+			f.outputs = append(f.outputs[:len(f.outputs):len(f.outputs)], o)
+			_, err := tfstate.ReadURL(t.Context(), base+"?outputsOnly=true")
+			if err == nil || !strings.Contains(err.Error(), "sensitive output "+o.name) {
+				t.Errorf("expected error for the sensitive output %s, got %v", o.name, err)
+			}
+		})
+	}
+
+	f.forbidState = true
+	for _, u := range []string{base, base + "?serial=1"} {
+		t.Run("forbidden "+u, func(t *testing.T) {
+			state, err := tfstate.ReadURL(t.Context(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testOutputs(t, state)
+		})
+	}
+
+	t.Run("remote backend", func(t *testing.T) {
+		src := fmt.Sprintf(`{"version": 3, "backend": {"type": "remote", "config": {"hostname": %q, "organization": "myorg", "workspaces": {"name": "app"}}}}`, f.Host())
+		state, err := tfstate.Read(t.Context(), strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testOutputs(t, state)
+	})
+}
+
+func TestReadTFECredentials(t *testing.T) {
+	f := newFakeTFE(t, "secret-token", map[string]string{"myorg/app": "ws-app"})
+	u := "remote://" + f.Host() + "/myorg/app"
+	envKey := "TF_TOKEN_" + strings.ReplaceAll(f.Host(), ".", "_")
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		credentials string // credentials.tfrc.json
+		cliConfig   string // TF_CLI_CONFIG_FILE
+	}{
+		{
+			name: "TF_TOKEN_{hostname}",
+			env:  map[string]string{envKey: "secret-token"},
+		},
+		{
+			name:        "TF_TOKEN_{hostname} takes precedence",
+			env:         map[string]string{envKey: "secret-token"},
+			credentials: `{"credentials": {%q: {"token": "wrong-token"}}}`,
+		},
+		{
+			name:        "credentials.tfrc.json",
+			credentials: `{"credentials": {%q: {"token": "secret-token"}}}`,
+			cliConfig:   `credentials %q { token = "wrong-token" }`,
+		},
+		{
+			name:      "credentials block",
+			cliConfig: `credentials %q { token = "secret-token" }`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("APPDATA", home)
+			t.Setenv("TFE_TOKEN", "")
+			t.Setenv("TF_CLI_CONFIG_FILE", filepath.Join(home, "terraformrc"))
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if tc.credentials != "" {
+				dir := filepath.Join(home, ".terraform.d")
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "credentials.tfrc.json"), fmt.Appendf(nil, tc.credentials, f.Host()), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.cliConfig != "" {
+				if err := os.WriteFile(filepath.Join(home, "terraformrc"), fmt.Appendf(nil, tc.cliConfig, f.Host()), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			state, err := tfstate.ReadURL(t.Context(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+		})
+	}
+}
@@ -9,11 +9,12 @@ import (
 	"testing"
 
 	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRoundTrip(t *testing.T) {
 	err := filepath.Walk("./roundtrip", func(path string, info os.FileInfo, err error) error {
-		if !strings.HasPrefix(info.Name(), "v4") {
+		if !strings.HasSuffix(info.Name(), ".tfstate") {
 			return nil
 		}
 		t.Logf("test roundtrip for %s", path)
@@ -53,3 +54,106 @@ func testLookupRoundTrip(t *testing.T, path string) error {
 	}
 	return nil
 }
+
+func TestLookupLegacyState(t *testing.T) {
+	tests := []struct {
+		path string
+		key  string
+		want any
+	}{
+		{"roundtrip/v1-simple.in.tfstate", "null_resource.bar.triggers.whaaat", "0,1"},
+		{"roundtrip/v1-simple.in.tfstate", "null_resource.foo[1].id", "3214385801340650197"},
+		{"roundtrip/v1-simple.in.tfstate", "output.numbers", "0,1"},
+		{"roundtrip/v3-grabbag.in.tfstate", "null_resource.baz.triggers.foo", "bar"},
+		{"roundtrip/v3-grabbag.in.tfstate", "null_resource.bar[1].triggers.index", "1"},
+		{"roundtrip/v3-grabbag.in.tfstate", "module.child.null_resource.foo.id", "1361"},
+		{"roundtrip/v3-grabbag.in.tfstate", "module.child.null_resource.foo.triggers", map[string]any{}},
+		{"roundtrip/v3-grabbag.in.tfstate", "output.results.aws_region", "us-west-2"},
+		{"roundtrip/v4-legacy-simple.in.tfstate", "null_resource.bar.triggers.whaaat", "0,1"},
+		{"roundtrip/v4-legacy-simple.in.tfstate", "null_resource.foo[1].triggers", map[string]any{"what": "0"}},
+		{"roundtrip/v4-legacy-modules.in.tfstate", `module.modB.null_resource.bar["b"].id`, "1523897709610803586"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"/"+tt.key, func(t *testing.T) {
+			state, err := tfstate.ReadFile(context.Background(), tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := state.Lookup(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(res.Value, tt.want); diff != "" {
+				t.Errorf("unexpected result %s", diff)
+			}
+		})
+	}
+}
+
+const testAttributesFlatState = `{
+  "version": 4,
+  "terraform_version": "0.12.0",
+  "serial": 1,
+  "lineage": "test",
+  "outputs": {},
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "x",
+      "provider": "provider.aws",
+      "instances": [
+        {
+          "schema_version": 1,
+          "attributes_flat": {
+            "id": "i-0123456789",
+            "tags.%": "2",
+            "tags.Name": "web",
+            "tags.kubernetes.io/cluster/main": "owned",
+            "security_groups.#": "2",
+            "security_groups.0": "sg-a",
+            "security_groups.1": "sg-b",
+            "ebs_block_device.#": "1",
+            "ebs_block_device.2576023345.device_name": "/dev/sdb",
+            "ebs_block_device.2576023345.volume_size": "10",
+            "root_block_device.#": "1",
+            "root_block_device.0.volume_size": "8",
+            "root_block_device.0.tags.%": "1",
+            "root_block_device.0.tags.Name": "root"
+          }
+        }
+      ]
+    }
+  ]
+}`
+
+func TestLookupAttributesFlat(t *testing.T) {
+	state, err := tfstate.Read(context.Background(), strings.NewReader(testAttributesFlatState))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want any
+	}{
+		{"aws_instance.x.id", "i-0123456789"},
+		{"aws_instance.x.tags.Name", "web"},
+		{`aws_instance.x.tags["kubernetes.io/cluster/main"]`, "owned"},
+		{"aws_instance.x.security_groups", []any{"sg-a", "sg-b"}},
+		{"aws_instance.x.security_groups[1]", "sg-b"},
+		{"aws_instance.x.ebs_block_device[0].device_name", "/dev/sdb"},
+		{"aws_instance.x.root_block_device[0].volume_size", "8"},
+		{"aws_instance.x.root_block_device[0].tags.Name", "root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			res, err := state.Lookup(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(res.Value, tt.want); diff != "" {
+				t.Errorf("unexpected result %s", diff)
+			}
+		})
+	}
+}
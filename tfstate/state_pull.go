@@ -0,0 +1,42 @@
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// statePullBinaries are the binaries to run "state pull" in order of preference.
+var statePullBinaries = []string{"terraform", "tofu"}
+
+// readStatePull reads the state of the workspace by "{binary} state pull" in dir.
+func readStatePull(ctx context.Context, binary string, dir string, ws string) (io.ReadCloser, error) {
+	if binary == "" {
+		for _, b := range statePullBinaries {
+			if _, err := exec.LookPath(b); err == nil {
+				binary = b
+				break
+			}
+		}
+		if binary == "" {
+			return nil, fmt.Errorf("none of %s is found in PATH for state pull", strings.Join(statePullBinaries, ", "))
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "state", "pull")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TF_WORKSPACE="+ws, "TF_IN_AUTOMATION=1")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s state pull: %w: %s", binary, err, msg)
+		}
+		return nil, fmt.Errorf("%s state pull: %w", binary, err)
+	}
+	return io.NopCloser(&stdout), nil
+}
@@ -0,0 +1,99 @@
+package tfstate_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// installFakeStatePull installs a fake binary of "state pull" which saves the working directory
+// and the workspace into pull.log and writes test/terraform.tfstate.
+func installFakeStatePull(t *testing.T, name string) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not executable on windows")
+	}
+	state, err := filepath.Abs("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	log := filepath.Join(binDir, "pull.log")
+	script := `#!/bin/sh
+[ "$1 $2" = "state pull" ] || { echo "unexpected args: $*" >&2; exit 1; }
+[ "$TF_WORKSPACE" = "broken" ] && { echo "Error: failed to read state" >&2; exit 1; }
+echo "$(pwd) $TF_WORKSPACE" > "` + log + `"
+exec /bin/cat "` + state + `"
+`
+	if err := os.WriteFile(filepath.Join(binDir, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return binDir, log
+}
+
+func TestReadStatePull(t *testing.T) {
+	binDir, log := installFakeStatePull(t, "tofu")
+	workDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(workDir, ".terraform", "terraform.tfstate")
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := `{"version": 3, "backend": {"type": "unsupported-store", "config": {"name": "network"}}}`
+	if err := os.WriteFile(stateFile, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		if _, err := tfstate.ReadURL(t.Context(), stateFile); err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		opt  tfstate.StatePullOption
+		ws   string
+	}{
+		{"binary", tfstate.StatePullOption{Binary: filepath.Join(binDir, "tofu")}, "default"},
+		{"tofu in PATH", tfstate.StatePullOption{}, "staging"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.opt.Binary == "" {
+				if _, err := exec.LookPath("terraform"); err == nil {
+					t.Skip("terraform is installed")
+				}
+				t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			}
+			t.Setenv("TF_WORKSPACE", tc.ws)
+			state, err := tfstate.ReadURL(t.Context(), stateFile, tc.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testLookupState(t, state)
+			b, err := os.ReadFile(log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, expected := strings.TrimSpace(string(b)), workDir+" "+tc.ws; got != expected {
+				t.Errorf("unexpected state pull %q, expected %q", got, expected)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "broken")
+		_, err := tfstate.ReadURL(t.Context(), stateFile, tfstate.StatePullOption{Binary: filepath.Join(binDir, "tofu")})
+		if err == nil || !strings.Contains(err.Error(), "failed to read state") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package tfstate
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// moduleV3 is a module of the state version 1 to 3.
+type moduleV3 struct {
+	Path      []string                   `json:"path"`
+	Outputs   map[string]json.RawMessage `json:"outputs"`
+	Resources map[string]resourceV3      `json:"resources"`
+}
+
+// resourceV3 is a resource of the state version 1 to 3.
+type resourceV3 struct {
+	Type      string      `json:"type"`
+	DependsOn []string    `json:"depends_on"`
+	Primary   *instanceV3 `json:"primary"`
+	Provider  string      `json:"provider"`
+}
+
+// instanceV3 is an instance of the state version 1 to 3.
+type instanceV3 struct {
+	ID         string            `json:"id"`
+	Attributes map[string]string `json:"attributes"`
+	Meta       map[string]any    `json:"meta"`
+}
+
+// resourceKeyV3 is a parsed key of resources of the state version 1 to 3,
+// such as "aws_instance.web", "aws_instance.web.1" or "data.aws_ami.ubuntu".
+type resourceKeyV3 struct {
+	mode  string
+	typ   string
+	name  string
+	index int // -1 for no index
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+func parseResourceKeyV3(key string) (resourceKeyV3, bool) {
+	k := resourceKeyV3{mode: "managed", index: -1}
+	parts := strings.Split(key, ".")
+	if parts[0] == "data" {
+		k.mode = "data"
+		parts = parts[1:]
+	}
+	switch len(parts) {
+	case 3:
+		i, err := strconv.Atoi(parts[2])
+		if err != nil || i < 0 {
+			return k, false
+		}
+		k.index = i
+	case 2:
+	default:
+		return k, false
+	}
+	k.typ, k.name = parts[0], parts[1]
+	if !identifierRegexp.MatchString(k.typ) || !identifierRegexp.MatchString(k.name) {
+		return k, false
+	}
+	return k, true
+}
+
+// upgradeV3 upgrades the state version 1 to 3 to the shape of version 4 in memory.
+// Module paths become module addresses, the flatmap attributes become nested attributes,
+// and depends_on of resources is carried over to the instances.
+func (s *tfstate) upgradeV3() {
+	s.Outputs = make(map[string]any)
+	s.Resources = nil
+	for _, m := range s.Modules {
+		module := moduleAddressV3(m.Path)
+		if module == "" {
+			for name, raw := range m.Outputs {
+				if v, ok := upgradeOutputV3(raw); ok {
+					s.Outputs[name] = v
+				}
+			}
+		}
+		s.Resources = append(s.Resources, upgradeResourcesV3(module, m.Resources)...)
+	}
+	s.Modules = nil
+	s.Version = StateVersion
+}
+
+// moduleAddressV3 converts a module path (["root", "a", "b"]) to the address ("module.a.module.b").
+func moduleAddressV3(path []string) string {
+	var addr []string
+	for i, p := range path {
+		if i == 0 && p == "root" {
+			continue
+		}
+		addr = append(addr, "module."+p)
+	}
+	return strings.Join(addr, ".")
+}
+
+// upgradeOutputV3 converts an output of version 1 (a string) or version 2 and 3 ({"type", "value", "sensitive"}).
+func upgradeOutputV3(raw json.RawMessage) (map[string]any, bool) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return map[string]any{"type": "string", "value": s}, true
+	}
+	var o struct {
+		Type      string `json:"type"`
+		Value     any    `json:"value"`
+		Sensitive bool   `json:"sensitive"`
+	}
+	if err := json.Unmarshal(raw, &o); err != nil || o.Value == nil {
+		return nil, false
+	}
+	if o.Type == "" {
+		o.Type = "string"
+	}
+	v := map[string]any{"type": o.Type, "value": o.Value}
+	if o.Sensitive {
+		v["sensitive"] = true
+	}
+	return v, true
+}
+
+func upgradeResourcesV3(module string, rs map[string]resourceV3) []resource {
+	keys := make([]string, 0, len(rs))
+	for key := range rs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var resources []resource
+	indexes := make(map[resourceKeyV3]int) // resource (with index -1) -> position in resources
+	for _, key := range keys {
+		r := rs[key]
+		k, ok := parseResourceKeyV3(key)
+		if !ok || r.Primary == nil {
+			continue
+		}
+		rk := resourceKeyV3{mode: k.mode, typ: k.typ, name: k.name, index: -1}
+		pos, ok := indexes[rk]
+		if !ok {
+			pos = len(resources)
+			indexes[rk] = pos
+			resources = append(resources, resource{
+				Module:   module,
+				Mode:     k.mode,
+				Type:     k.typ,
+				Name:     k.name,
+				Provider: r.Provider,
+			})
+		}
+		inst := instance{
+			Attributes: upgradeAttributesV3(r.Primary),
+			DependsOn:  upgradeDependsOnV3(r.DependsOn),
+		}
+		if v, ok := r.Primary.Meta["schema_version"].(string); ok {
+			inst.SchemaVersion, _ = strconv.Atoi(v)
+		}
+		if k.index >= 0 {
+			inst.IndexKey = json.RawMessage(strconv.Itoa(k.index))
+		}
+		resources[pos].Instances = append(resources[pos].Instances, inst)
+	}
+
+	for i := range resources {
+		upgradeIndexesV3(&resources[i])
+	}
+	return resources
+}
+
+func upgradeAttributesV3(p *instanceV3) map[string]any {
+	attrs := unflatten(p.Attributes)
+	if _, ok := attrs["id"]; !ok && p.ID != "" {
+		attrs["id"] = p.ID
+	}
+	return attrs
+}
+
+// upgradeIndexesV3 orders the instances of a counted resource by the index.
+// An instance without the index among counted ones (a resource with count = 1 of old terraform) is the index 0.
+func upgradeIndexesV3(r *resource) {
+	counted := false
+	for _, inst := range r.Instances {
+		if len(inst.IndexKey) > 0 {
+			counted = true
+			break
+		}
+	}
+	if !counted {
+		return
+	}
+	r.Each = "list"
+	for i := range r.Instances {
+		if len(r.Instances[i].IndexKey) == 0 {
+			r.Instances[i].IndexKey = json.RawMessage("0")
+		}
+	}
+	sort.SliceStable(r.Instances, func(i, j int) bool {
+		a, _ := strconv.Atoi(string(r.Instances[i].IndexKey))
+		b, _ := strconv.Atoi(string(r.Instances[j].IndexKey))
+		return a < b
+	})
+}
+
+// upgradeDependsOnV3 converts dependencies ("aws_instance.web.*", "aws_instance.web.1")
+// to the addresses ("aws_instance.web", "aws_instance.web[1]"). Invalid ones are dropped.
+func upgradeDependsOnV3(deps []string) []string {
+	var addrs []string
+	for _, dep := range deps {
+		if strings.HasPrefix(dep, "module.") {
+			addrs = append(addrs, dep)
+			continue
+		}
+		k, ok := parseResourceKeyV3(strings.TrimSuffix(dep, ".*"))
+		if !ok {
+			continue
+		}
+		addr := k.typ + "." + k.name
+		if k.mode == "data" {
+			addr = "data." + addr
+		}
+		if k.index >= 0 {
+			addr += "[" + strconv.Itoa(k.index) + "]"
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
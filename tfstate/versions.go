@@ -0,0 +1,153 @@
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// StateVersionInfo represents a version of a state stored in a remote backend.
+type StateVersionInfo struct {
+	VersionID    string    `json:"version_id"`
+	Serial       int64     `json:"serial"`
+	LastModified time.Time `json:"last_modified"`
+	IsLatest     bool      `json:"is_latest"`
+
+	// Snapshot reports whether the version is a blob snapshot of AzureRM.
+	// VersionID of a snapshot is selected by the snapshot query instead of the versionid query.
+	Snapshot bool `json:"snapshot,omitempty"`
+
+	// RunID is the ID of the run which created the state version of TFE.
+	RunID string `json:"run_id,omitempty"`
+}
+
+// ListStateVersions lists the versions of the state at the URL, newest first.
+// The versions are listed by the backend of the URL scheme which implements StateVersionLister.
+// The built-in backends read the beginning of each version to get the serial,
+// so listing sends a small ranged request per version in addition to the list requests.
+// The built-in ones are s3 (with bucket versioning), gs (with object versioning),
+// azurerm (snapshots and blob versioning) and remote (state versions of TFE).
+func ListStateVersions(ctx context.Context, loc string, opts ...ReadURLOption) ([]StateVersionInfo, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newReadURLConfig()
+	for _, opt := range opts {
+		opt.applyReadURLConfig(cfg)
+	}
+
+	s, ok := lookupScheme(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("URL scheme %s is not supported", u.Scheme)
+	}
+	l, ok := s.backend.(StateVersionLister)
+	if !ok {
+		return nil, fmt.Errorf("listing versions of URL scheme %s is not supported", u.Scheme)
+	}
+	config, ws, err := s.parseURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %w", u.Redacted(), err)
+	}
+	versions, err := l.ListStateVersions(withReadURLConfig(ctx, cfg), config, ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %w", u.Redacted(), err)
+	}
+	return versions, nil
+}
+
+// stateVersion returns the version selector of the state.
+// VersionIDOption and AsOfOption of ReadURL take precedence over version_id and as_of of the config,
+// which are converted from the URL query.
+func stateVersion(ctx context.Context, config map[string]any) (string, time.Time, error) {
+	if c := readURLConfigFrom(ctx); c != nil && (c.versionID != "" || !c.asOf.IsZero()) {
+		return c.versionID, c.asOf, nil
+	}
+	var asOf time.Time
+	if v := *strpe(config["as_of"]); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid asOf %q: %w", v, err)
+		}
+		asOf = t
+	}
+	return *strpe(config["version_id"]), asOf, nil
+}
+
+// stateSerialPrefixSize is the size of the beginning of a state to read the serial when listing versions.
+// Terraform writes "serial" next to "version" and "terraform_version" at the beginning of a state.
+const stateSerialPrefixSize = 1024
+
+// readVersionSerial reads the serial of a version of a state.
+// open opens the first size bytes of the version, or the whole of it when size is 0.
+// Only the beginning is read not to download each version, and the whole is read
+// when the serial is not found in the beginning.
+func readVersionSerial(open func(size int64) (io.ReadCloser, error)) (int64, error) {
+	r, err := open(stateSerialPrefixSize)
+	if err != nil {
+		return 0, err
+	}
+	b, err := io.ReadAll(io.LimitReader(r, stateSerialPrefixSize))
+	r.Close()
+	if err != nil {
+		return 0, err
+	}
+	complete := len(b) < stateSerialPrefixSize
+	if serial, ok := scanStateSerial(b, complete); ok {
+		return serial, nil
+	}
+	if complete {
+		return readStateSerial(bytes.NewReader(b))
+	}
+	if r, err = open(0); err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return readStateSerial(r)
+}
+
+// scanStateSerial finds the serial in the top level keys of the beginning of a state.
+// complete reports whether b is the whole state, otherwise a number at the end of b may be truncated.
+func scanStateSerial(b []byte, complete bool) (int64, bool) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return 0, false
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return 0, false
+		}
+		if key, _ := t.(string); key != "serial" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return 0, false
+			}
+			continue
+		}
+		var n json.Number
+		if err := dec.Decode(&n); err != nil || (!complete && dec.InputOffset() >= int64(len(b))) {
+			return 0, false
+		}
+		serial, err := n.Int64()
+		return serial, err == nil
+	}
+	return 0, false
+}
+
+// readStateSerial reads the serial of a state.
+func readStateSerial(r io.Reader) (int64, error) {
+	var s struct {
+		Serial int64 `json:"serial"`
+	}
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return 0, err
+	}
+	return s.Serial, nil
+}